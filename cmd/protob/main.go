@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"os"
+	"os/signal"
 	"protob/internal/subcommand"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -17,9 +20,27 @@ var (
 func main() {
 	root := cobra.Command{Use: "protob"}
 
+	root.AddCommand(subcommand.Breaking())
+	root.AddCommand(subcommand.Clean())
 	root.AddCommand(subcommand.Compile())
+	root.AddCommand(subcommand.Descriptor())
+	root.AddCommand(subcommand.Deps())
+	root.AddCommand(subcommand.Format())
 	root.AddCommand(subcommand.Install())
+	root.AddCommand(subcommand.Lint())
+	root.AddCommand(subcommand.Verify())
 	root.AddCommand(subcommand.Version(Version, GitRevision, BuildTime))
 
-	_ = root.ExecuteContext(context.TODO())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		cancel()
+	}()
+
+	_ = root.ExecuteContext(ctx)
 }
@@ -11,4 +11,5 @@ require (
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.1
 	golang.org/x/sys v0.0.0-20210219172841-57ea560cfca1 // indirect
+	google.golang.org/protobuf v1.27.1
 )
@@ -0,0 +1,373 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"protob/internal/cache"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Unit represents targets compiled in one invocation of the compiler
+type Unit struct {
+	// runtime of the compiler
+	Runtime *protobuf.CompilerRuntime
+
+	// protobuf files to compile
+	Targets []string
+}
+
+// String returns the display name of the unit
+func (unit *Unit) String() string {
+	return strings.Join(unit.Targets, ", ")
+}
+
+// NewUnits split targets into units by directory and package
+func NewUnits(runtime *protobuf.CompilerRuntime, targets []string) ([]*Unit, error) {
+	batches, err := protobuf.GroupTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []*Unit
+	for _, batch := range batches {
+		units = append(units, &Unit{Runtime: runtime, Targets: batch})
+	}
+	return units, nil
+}
+
+// Failure represents an unit failed to compile
+type Failure struct {
+	// the failed unit
+	Unit *Unit
+
+	// error returned by the compiler
+	Err error
+}
+
+// Report represents the result of a build
+type Report struct {
+	// units compiled successfully
+	Succeeded []*Unit
+
+	// units up to date, not compiled again
+	Cached []*Unit
+
+	// units failed to compile
+	Failures []*Failure
+
+	// units not compiled because of early stop or cancellation
+	Skipped []*Unit
+
+	// absolute paths of the files generated by units succeeded or cached
+	Outputs map[*Unit][]string
+
+	// staging directories of the output directories of units succeeded,
+	// only when compiled into a stage
+	Staging map[*Unit]map[string]string
+}
+
+// Failed reports whether any unit failed to compile
+func (r *Report) Failed() bool {
+	return len(r.Failures) != 0
+}
+
+// options represents options to control the build
+type options struct {
+	// maximum number of units compiled at the same time
+	jobs int
+
+	// cache of the compile results
+	cache *cache.Cache
+
+	// compile even if the unit is up to date
+	force bool
+
+	// compile all units even if any failed
+	keepGoing bool
+
+	// maximum duration of each compiler invocation, no limit if zero
+	timeout time.Duration
+
+	// directory which units are compiled into instead of the output
+	// directories, nothing installed if set
+	stage string
+}
+
+// Option represents an option to control the build
+type Option func(*options)
+
+// WithJobs sets the maximum number of units compiled at the same time,
+// with only one job the build stops at the first failure unless keep going
+func WithJobs(jobs int) Option {
+	return func(opts *options) {
+		if jobs > 0 {
+			opts.jobs = jobs
+		}
+	}
+}
+
+// WithCache sets the cache to skip units which inputs are not changed
+func WithCache(c *cache.Cache) Option {
+	return func(opts *options) {
+		opts.cache = c
+	}
+}
+
+// WithForce compile all units even if they are up to date
+func WithForce(force bool) Option {
+	return func(opts *options) {
+		opts.force = force
+	}
+}
+
+// WithKeepGoing compile all units even if any failed
+func WithKeepGoing(keepGoing bool) Option {
+	return func(opts *options) {
+		opts.keepGoing = keepGoing
+	}
+}
+
+// WithTimeout sets the maximum duration of each compiler invocation, the
+// compiler is killed and the unit fails when exceeded
+func WithTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.timeout = timeout
+	}
+}
+
+// WithStage compile units into directories under stage without
+// installing into the output directories, the cache is not used
+func WithStage(stage string) Option {
+	return func(opts *options) {
+		opts.stage = stage
+	}
+}
+
+// result represents the result of one unit
+type result struct {
+	done    bool
+	cached  bool
+	outputs []string
+	staging map[string]string
+	err     error
+}
+
+// Run compile all units by a bounded pool of workers, the units are
+// reported in the same order as given, units not finished when ctx is
+// done are reported as skipped
+func Run(ctx context.Context, compiler protobuf.Compiler, units []*Unit, opts ...Option) *Report {
+	o := &options{jobs: 1}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	results := make([]result, len(units))
+
+	var mu sync.Mutex
+	stopped := false
+
+	binaries := &digests{}
+	var wg sync.WaitGroup
+	queue := make(chan int)
+	for i := 0; i < o.jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				mu.Lock()
+				stop := stopped
+				mu.Unlock()
+				if stop || ctx.Err() != nil {
+					continue
+				}
+
+				r := execute(ctx, compiler, units[index], index, binaries, o)
+				if ctx.Err() != nil {
+					continue
+				}
+
+				mu.Lock()
+				results[index] = r
+				if r.err != nil && o.jobs == 1 && !o.keepGoing {
+					stopped = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for index := range units {
+		mu.Lock()
+		stop := stopped
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			break
+		}
+		queue <- index
+	}
+	close(queue)
+	wg.Wait()
+
+	report := &Report{Outputs: make(map[*Unit][]string), Staging: make(map[*Unit]map[string]string)}
+	for index, unit := range units {
+		switch r := results[index]; {
+		case !r.done:
+			report.Skipped = append(report.Skipped, unit)
+		case r.err != nil:
+			report.Failures = append(report.Failures, &Failure{Unit: unit, Err: r.err})
+		case r.cached:
+			report.Cached = append(report.Cached, unit)
+			report.Outputs[unit] = r.outputs
+		default:
+			report.Succeeded = append(report.Succeeded, unit)
+			report.Outputs[unit] = r.outputs
+			if r.staging != nil {
+				report.Staging[unit] = r.staging
+			}
+		}
+	}
+	return report
+}
+
+// execute compile the unit at index unless it is up to date in the
+// cache, or into the stage if set, binaries memoizes the plugin hashes
+func execute(ctx context.Context, compiler protobuf.Compiler, unit *Unit, index int, binaries *digests, o *options) result {
+	var key string
+	if o.cache != nil && o.stage == "" {
+		var err error
+		if key, err = fingerprint(compiler, unit, binaries); err != nil {
+			return result{done: true, err: err}
+		}
+
+		if entry, ok := o.cache.Lookup(key); ok && !o.force {
+			return result{done: true, cached: true, outputs: entry.Outputs}
+		}
+	}
+
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	r := result{done: true}
+	if o.stage != "" {
+		r.staging, r.err = Stage(ctx, compiler, unit, fs.Join(o.stage, strconv.Itoa(index)))
+	} else {
+		r.outputs, r.err = Compile(ctx, compiler, unit)
+	}
+	if r.err == context.DeadlineExceeded {
+		r.err = fmt.Errorf("compile: timed out after %s", o.timeout)
+	}
+	if r.err != nil || key == "" {
+		return r
+	}
+
+	if err := o.cache.Store(key, &cache.Entry{Outputs: r.outputs}); err != nil {
+		return result{done: true, err: err}
+	}
+	return r
+}
+
+// Compile compile the unit into staging directories, then move generated
+// files into the output directories, returns absolute paths of the outputs,
+// the output directories are untouched if the compile failed or ctx is done
+func Compile(ctx context.Context, compiler protobuf.Compiler, unit *Unit) ([]string, error) {
+	stage, err := ioutil.TempDir("", "protob-stage")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(stage) }()
+
+	staging, err := Stage(ctx, compiler, unit, stage)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := install(ctx, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(outputs)
+	return outputs, nil
+}
+
+// Stage compile the unit into directories under stage instead of the
+// output directories, returns the staging directory of every output
+func Stage(ctx context.Context, compiler protobuf.Compiler, unit *Unit, stage string) (map[string]string, error) {
+	staging := make(map[string]string)
+	for i, output := range unit.Runtime.OutputDirs(unit.Targets) {
+		staging[output] = fs.Join(stage, strconv.Itoa(i))
+		if err := os.MkdirAll(staging[output], fs.DirectoryPerm); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := compiler.Compile(ctx, unit.Targets, unit.Runtime.Clone(protobuf.WithStaging(staging))); err != nil {
+		return nil, err
+	}
+	return staging, nil
+}
+
+// install copy all files in staging directories next to their paths in
+// the output directories, then rename them into place, no file is
+// replaced if any copy failed or ctx is done
+func install(ctx context.Context, staging map[string]string) ([]string, error) {
+	temps := make(map[string]string)
+	defer func() {
+		for _, temp := range temps {
+			_ = os.Remove(temp)
+		}
+	}()
+
+	for output, stage := range staging {
+		err := filepath.Walk(stage, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			} else if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(stage, path)
+			if err != nil {
+				return err
+			}
+
+			dst, err := filepath.Abs(filepath.Join(output, rel))
+			if err != nil {
+				return err
+			}
+
+			temp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".protob")
+			temps[fs.NormalizePath(dst)] = temp
+			return fs.CopyFile(path, temp, info.Mode().Perm())
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var outputs []string
+	for dst, temp := range temps {
+		if err := os.Rename(temp, dst); err != nil {
+			return nil, err
+		}
+		delete(temps, dst)
+		outputs = append(outputs, dst)
+	}
+	return outputs, nil
+}
@@ -0,0 +1,226 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"protob/internal/cache"
+	"protob/pkg/os/fs"
+	"protob/pkg/os/fs/fstest"
+	"protob/pkg/protobuf"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// prepareUnits returns units of one target each, compiled by plugin go
+// into the gen directory of root
+func prepareUnits(t *testing.T, names ...string) (string, []*Unit) {
+	files := make(map[string]string)
+	for _, name := range names {
+		files["api/"+name+"/"+name+".proto"] = "syntax = \"proto3\";\npackage " + name + ";\n"
+	}
+	root := fstest.TempDir(t, files)
+
+	runtime := protobuf.NewCompileRuntime(protobuf.WithPlugins(&protobuf.Plugin{Name: "go", Out: fs.Join(root, "gen")}))
+	var units []*Unit
+	for _, name := range names {
+		units = append(units, &Unit{Runtime: runtime, Targets: []string{fs.Join(root, "api", name, name+".proto")}})
+	}
+	return root, units
+}
+
+func TestRun(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	tests := []struct {
+		name      string
+		jobs      int
+		keepGoing bool
+		timeout   time.Duration
+		failing   string
+		hang      string
+		succeeded int
+		failures  int
+		skipped   int
+	}{
+		{name: "all succeeded", jobs: 1, succeeded: 3},
+		{name: "stop at first failure", jobs: 1, failing: "a", failures: 1, skipped: 2},
+		{name: "keep going", jobs: 1, keepGoing: true, failing: "a", succeeded: 2, failures: 1},
+		{name: "parallel", jobs: 3, failing: "b", succeeded: 2, failures: 1},
+		{name: "timeout", jobs: 2, timeout: 50 * time.Millisecond, hang: "c", succeeded: 2, failures: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, units := prepareUnits(t, "a", "b", "c")
+
+			var running, peak int32
+			compiler := protobuf.NewFakeCompiler("libprotoc 3.15.0", protobuf.CapArguments)
+			compiler.CompileFunc = func(ctx context.Context, targets []string, runtime *protobuf.CompilerRuntime) error {
+				defer atomic.AddInt32(&running, -1)
+				if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&peak) {
+					atomic.StoreInt32(&peak, n)
+				}
+				time.Sleep(10 * time.Millisecond)
+
+				// outputs of failed units are generated but never installed
+				if err := protobuf.FakeGenerate(ctx, targets, runtime); err != nil {
+					return err
+				}
+				switch filepath.Base(filepath.Dir(targets[0])) {
+				case test.failing:
+					return errBroken
+				case test.hang:
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				return nil
+			}
+
+			report := Run(context.Background(), compiler, units,
+				WithJobs(test.jobs), WithKeepGoing(test.keepGoing), WithTimeout(test.timeout))
+			if len(report.Succeeded) != test.succeeded || len(report.Failures) != test.failures || len(report.Skipped) != test.skipped {
+				t.Fatalf("Run() = %d succeeded, %d failed, %d skipped, expected %d, %d, %d",
+					len(report.Succeeded), len(report.Failures), len(report.Skipped), test.succeeded, test.failures, test.skipped)
+			}
+			if int(peak) > test.jobs {
+				t.Errorf("Run() ran %d compiles at the same time, expected at most %d", peak, test.jobs)
+			}
+
+			for _, failure := range report.Failures {
+				if test.hang != "" && !strings.Contains(failure.Err.Error(), "timed out") {
+					t.Errorf("Run() failure = %v, expected timed out", failure.Err)
+				}
+				name := strings.TrimSuffix(filepath.Base(failure.Unit.Targets[0]), ".proto") + ".pb.go"
+				if ok, _ := fs.IsFile(fs.Join(root, "gen", name)); ok {
+					t.Errorf("Run() installed %s of the failed unit", name)
+				}
+			}
+			for _, unit := range report.Succeeded {
+				outputs := report.Outputs[unit]
+				if len(outputs) != 1 {
+					t.Errorf("Run() outputs of %s = %v, expected one file", unit, outputs)
+				} else if ok, _ := fs.IsFile(outputs[0]); !ok {
+					t.Errorf("Run() output %s not installed", outputs[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	_, units := prepareUnits(t, "a", "b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	compiler := protobuf.NewFakeCompiler("libprotoc 3.15.0", protobuf.CapArguments)
+	compiler.CompileFunc = func(ctx context.Context, targets []string, runtime *protobuf.CompilerRuntime) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	report := Run(ctx, compiler, units)
+	if len(report.Skipped) != 2 || report.Failed() {
+		t.Errorf("Run() = %d skipped, %d failed, expected all skipped", len(report.Skipped), len(report.Failures))
+	}
+}
+
+func TestRunCache(t *testing.T) {
+	root, units := prepareUnits(t, "a", "b")
+	c := cache.New(fstest.TempDir(t, nil))
+	compiler := protobuf.NewFakeCompiler("libprotoc 3.15.0", protobuf.CapArguments)
+	compiler.CompileFunc = protobuf.FakeGenerate
+
+	tests := []struct {
+		name     string
+		prepare  func()
+		force    bool
+		compiled int
+		cached   int
+	}{
+		{name: "cache miss", compiled: 2},
+		{name: "cache hit", cached: 2},
+		{name: "force", force: true, compiled: 2},
+		{name: "target changed", prepare: func() {
+			fstest.WriteFiles(t, root, map[string]string{"api/a/a.proto": "syntax = \"proto3\";\npackage a;\nmessage A {}\n"})
+		}, compiled: 1, cached: 1},
+		{name: "output removed", prepare: func() {
+			_ = os.Remove(filepath.Join(root, "gen", "b.pb.go"))
+		}, compiled: 1, cached: 1},
+	}
+
+	for _, test := range tests {
+		if test.prepare != nil {
+			test.prepare()
+		}
+		compiler.Reset()
+
+		report := Run(context.Background(), compiler, units, WithCache(c), WithForce(test.force))
+		if len(report.Succeeded) != test.compiled || len(report.Cached) != test.cached || len(compiler.Invocations()) != test.compiled {
+			t.Errorf("%s: Run() = %d compiled, %d cached, expected %d, %d",
+				test.name, len(report.Succeeded), len(report.Cached), test.compiled, test.cached)
+		}
+		for _, unit := range report.Cached {
+			if len(report.Outputs[unit]) != 1 {
+				t.Errorf("%s: Run() outputs of cached %s = %v, expected recorded outputs", test.name, unit, report.Outputs[unit])
+			}
+		}
+	}
+}
+
+func TestRunStage(t *testing.T) {
+	root, units := prepareUnits(t, "a", "b")
+	compiler := protobuf.NewFakeCompiler("libprotoc 3.15.0", protobuf.CapArguments)
+	compiler.CompileFunc = protobuf.FakeGenerate
+
+	report := Run(context.Background(), compiler, units, WithStage(fstest.TempDir(t, nil)), WithJobs(2))
+	if len(report.Succeeded) != 2 {
+		t.Fatalf("Run() = %d succeeded, expected 2", len(report.Succeeded))
+	}
+	for _, unit := range report.Succeeded {
+		name := filepath.Base(filepath.Dir(unit.Targets[0])) + ".pb.go"
+		staging := report.Staging[unit][fs.Join(root, "gen")]
+		if ok, _ := fs.IsFile(filepath.Join(staging, name)); !ok {
+			t.Errorf("Run() staging of %s = %v, expected %s staged", unit, report.Staging[unit], name)
+		}
+		if ok, _ := fs.IsFile(filepath.Join(root, "gen", name)); ok {
+			t.Errorf("Run() installed %s, expected only staged", name)
+		}
+	}
+}
+
+func TestFingerprintPluginBinary(t *testing.T) {
+	root := fstest.TempDir(t, map[string]string{
+		"api/a/a.proto":     "syntax = \"proto3\";\npackage a;\n",
+		"bin/protoc-gen-go": "v1",
+	})
+	binary := fs.Join(root, "bin", "protoc-gen-go")
+	stat, err := os.Stat(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := protobuf.NewCompileRuntime(protobuf.WithPlugins(&protobuf.Plugin{Name: "go", Out: fs.Join(root, "gen"), Path: binary}))
+	unit := &Unit{Runtime: runtime, Targets: []string{fs.Join(root, "api", "a", "a.proto")}}
+	compiler := protobuf.NewFakeCompiler("libprotoc 3.15.0", protobuf.CapArguments)
+
+	before, err := Fingerprint(compiler, unit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reinstalled binary of the same size with the modification time preserved
+	fstest.WriteFiles(t, root, map[string]string{"bin/protoc-gen-go": "v2"})
+	if err := os.Chtimes(binary, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	if after, err := Fingerprint(compiler, unit); err != nil {
+		t.Fatal(err)
+	} else if after == before {
+		t.Errorf("Fingerprint() = %s after the plugin binary changed, expected a new key", after)
+	}
+}
@@ -0,0 +1,105 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"protob/pkg/protobuf"
+	"sync"
+)
+
+// Fingerprint returns the hash of all inputs of the unit: the compiler
+// version, the arguments, content of targets and their transitive
+// imports and the plugin binaries
+func Fingerprint(compiler protobuf.Compiler, unit *Unit) (string, error) {
+	return fingerprint(compiler, unit, &digests{})
+}
+
+// fingerprint returns the hash of all inputs of the unit, the plugin
+// binaries are hashed once by binaries
+func fingerprint(compiler protobuf.Compiler, unit *Unit, binaries *digests) (string, error) {
+	hash := sha256.New()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	_, _ = fmt.Fprintf(hash, "wd\x00%s\n", wd)
+	_, _ = fmt.Fprintf(hash, "compiler\x00%s\n", compiler.Version())
+	for _, arg := range unit.Runtime.Build(unit.Targets) {
+		_, _ = fmt.Fprintf(hash, "arg\x00%s\n", arg)
+	}
+
+	imports, missing, err := protobuf.Imports(unit.Runtime.Includes(unit.Targets), unit.Targets)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range append(append([]string{}, unit.Targets...), imports...) {
+		if err := hashFile(hash, file); err != nil {
+			return "", err
+		}
+	}
+	for _, name := range missing {
+		_, _ = fmt.Fprintf(hash, "missing\x00%s\n", name)
+	}
+
+	for _, plugin := range unit.Runtime.Plugins() {
+		_, _ = fmt.Fprintf(hash, "plugin\x00%s\n", plugin)
+		if path, err := unit.Runtime.PluginPath(plugin); err == nil {
+			digest, err := binaries.digest(path)
+			if err != nil {
+				return "", err
+			}
+			_, _ = fmt.Fprintf(hash, "binary\x00%s\x00%s\n", path, digest)
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashFile writes path and content of file into hash
+func hashFile(hash io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	_, _ = fmt.Fprintf(hash, "file\x00%s\n", path)
+	_, err = io.Copy(hash, file)
+	return err
+}
+
+// digests memoizes the content hash of plugin binaries during one run,
+// so a binary shared by many units is read only once
+type digests struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+// digest returns the content hash of the binary at path
+func (d *digests) digest(path string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if digest, ok := d.hashes[path]; ok {
+		return digest, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	if d.hashes == nil {
+		d.hashes = make(map[string]string)
+	}
+	d.hashes[path] = hex.EncodeToString(hash.Sum(nil))
+	return d.hashes[path], nil
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"protob/pkg/os/fs"
+)
+
+// Entry represents a cached result of one compile
+type Entry struct {
+	// files generated by the compile
+	Outputs []string `json:"outputs"`
+}
+
+// Cache represents a content-addressed store of compile results
+type Cache struct {
+	// directory of the cache
+	dir string
+}
+
+// Lookup returns the entry of key, the entry is valid only when all
+// outputs are still present
+func (c *Cache) Lookup(key string) (*Entry, bool) {
+	content, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, false
+	}
+
+	for _, output := range entry.Outputs {
+		if ok, _ := fs.IsFile(output); !ok {
+			return nil, false
+		}
+	}
+	return &entry, true
+}
+
+// Store saves entry with key into the cache
+func (c *Cache) Store(key string, entry *Entry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(c.path(key), bytes.NewReader(content), fs.RegularFilePerm)
+}
+
+// path returns path of the entry file
+func (c *Cache) path(key string) string {
+	return fs.Join(c.dir, key[:2], key+".json")
+}
+
+// New create a cache stored in dir
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"protob/pkg/os/fs"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// Filename is the name of the project configuration file
+	Filename = "protob.yaml"
+)
+
+var (
+	// ErrNotFound represents unable to found configuration file from working directory to root
+	ErrNotFound = errors.New("config: protob.yaml not found")
+)
+
+// Config represents the project configuration declared in protob.yaml
+type Config struct {
+	// groups of targets compiled with the same options
+	Groups []*Group `mapstructure:"groups"`
+
+	// rules of protob lint
+	Lint Lint `mapstructure:"lint"`
+
+	// remote dependencies added into include paths of all groups
+	Deps []*Dependency `mapstructure:"deps"`
+
+	// directory of the configuration file
+	dir string
+}
+
+// Dir returns directory of the configuration file
+func (cfg *Config) Dir() string {
+	return cfg.dir
+}
+
+// Path returns path relative to the configuration file
+func (cfg *Config) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return fs.NormalizePath(path)
+	}
+
+	abs := filepath.Join(cfg.dir, path)
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, abs); err == nil {
+			return fs.NormalizePath(rel)
+		}
+	}
+	return fs.NormalizePath(abs)
+}
+
+// Paths returns paths relative to the configuration file
+func (cfg *Config) Paths(paths []string) []string {
+	var result []string
+	for _, path := range paths {
+		result = append(result, cfg.Path(path))
+	}
+	return result
+}
+
+// Group represents a set of targets compiled with the same options
+type Group struct {
+	// name of the group, only for display
+	Name string `mapstructure:"name"`
+
+	// protobuf files, directories or patterns to compile
+	Targets []string `mapstructure:"targets"`
+
+	// patterns to exclude from targets
+	Exclude []string `mapstructure:"exclude"`
+
+	// protobuf dependencies
+	Include []string `mapstructure:"include"`
+
+	// output directory
+	Output string `mapstructure:"output"`
+
+	// preset of plugins: gogo extension fast, faster, slick or golang
+	// which using protoc-gen-go and protoc-gen-go-grpc
+	Extension string `mapstructure:"extension"`
+
+	// go module path removed from the output path of golang extension
+	Module string `mapstructure:"module"`
+
+	// whether compile with grpc
+	Grpc bool `mapstructure:"grpc"`
+
+	// compiler options: source_relative
+	SourceRelative bool `mapstructure:"source_relative"`
+
+	// plugins to generate code
+	Plugins []*Plugin `mapstructure:"plugins"`
+
+	// code generators built in protoc, e.g. python, java, the path of
+	// the languages is ignored
+	Languages []*Plugin `mapstructure:"languages"`
+}
+
+// Plugin represents a protoc plugin named protoc-gen-<name>
+type Plugin struct {
+	// name of the plugin without prefix
+	Name string `mapstructure:"name"`
+
+	// output directory, the output of group by default
+	Out string `mapstructure:"out"`
+
+	// path of the plugin executable
+	Path string `mapstructure:"path"`
+
+	// parameters passed to the plugin
+	Parameters []string `mapstructure:"parameters"`
+}
+
+// Dependency represents protobuf files from a git repository or an
+// archive, fetched by protob deps fetch
+type Dependency struct {
+	// name of the dependency, unique in project
+	Name string `mapstructure:"name"`
+
+	// url of the git repository
+	Git string `mapstructure:"git"`
+
+	// branch, tag or commit of the git repository
+	Ref string `mapstructure:"ref"`
+
+	// url of the tarball or zip archive
+	URL string `mapstructure:"url"`
+
+	// directory in the source used as include path
+	Subdir string `mapstructure:"subdir"`
+}
+
+// Lint represents the rules enabled or disabled besides the defaults
+type Lint struct {
+	// rules to enable, "all" for every rule
+	Enable []string `mapstructure:"enable"`
+
+	// rules to disable, "all" for every rule
+	Disable []string `mapstructure:"disable"`
+}
+
+// Find lookup configuration file from dir up to the root
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, Filename)
+		if ok, _ := fs.IsFile(path); ok {
+			return fs.NormalizePath(path), nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNotFound
+		}
+		dir = parent
+	}
+}
+
+// Load read and parse configuration file from path
+func Load(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{dir: filepath.Dir(abs)}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+
+	for i, group := range cfg.Groups {
+		if group == nil {
+			return nil, errors.New("config: empty group declared")
+		} else if len(group.Targets) == 0 {
+			return nil, fmt.Errorf("config: no targets declared in group %s", groupName(group, i))
+		}
+
+		for _, plugin := range group.Plugins {
+			if plugin == nil || plugin.Name == "" {
+				return nil, fmt.Errorf("config: plugin without name in group %s", groupName(group, i))
+			}
+		}
+		for _, language := range group.Languages {
+			if language == nil || language.Name == "" {
+				return nil, fmt.Errorf("config: language without name in group %s", groupName(group, i))
+			}
+		}
+
+		switch group.Extension {
+		case "", "fast", "faster", "slick", "golang":
+		default:
+			return nil, fmt.Errorf("config: unknown extension '%s' in group %s", group.Extension, groupName(group, i))
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, dependency := range cfg.Deps {
+		if dependency == nil || dependency.Name == "" {
+			return nil, errors.New("config: dependency without name")
+		} else if names[dependency.Name] {
+			return nil, fmt.Errorf("config: dependency %s declared more than once", dependency.Name)
+		}
+		names[dependency.Name] = true
+	}
+
+	return cfg, nil
+}
+
+// Lookup find and load configuration file from working directory
+func Lookup() (*Config, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := Find(wd)
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// groupName returns the display name of the group
+func groupName(group *Group, index int) string {
+	if group.Name != "" {
+		return "'" + group.Name + "'"
+	}
+	return fmt.Sprintf("#%d", index)
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"path/filepath"
+	"protob/pkg/os/fs/fstest"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	dir := fstest.TempDir(t, map[string]string{Filename: "groups: []\n", "api/v1/echo.proto": ""})
+	nested := filepath.Join(dir, "api", "v1")
+
+	path, err := Find(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := filepath.Abs(filepath.Join(dir, Filename)); path != filepath.ToSlash(expected) {
+		t.Errorf("Find() = %s, expected %s", path, expected)
+	}
+
+	if _, err := Find(filepath.Join(string(filepath.Separator), "protob-not-exist")); err != ErrNotFound {
+		t.Errorf("Find() = %v, expected %v", err, ErrNotFound)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		content string
+		valid   bool
+	}{
+		{"groups:\n  - targets: [api]\n    extension: golang\n", true},
+		{"groups:\n  - name: api\n", false},
+		{"groups:\n  - targets: [api]\n    extension: unknown\n", false},
+		{"groups:\n  - targets: [api]\n    plugins:\n      - out: gen\n", false},
+		{"groups:\n  - targets: [api]\n    languages:\n      - out: gen\n", false},
+		{"deps:\n  - git: https://example.com/acme.git\n", false},
+		{"deps:\n  - name: acme\n    git: https://example.com/a.git\n  - name: acme\n    git: https://example.com/b.git\n", false},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(fstest.TempDir(t, map[string]string{Filename: test.content}), Filename)
+		if _, err := Load(path); (err == nil) != test.valid {
+			t.Errorf("Load(%q) = %v, expected valid %v", test.content, err, test.valid)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	dir := fstest.TempDir(t, map[string]string{Filename: "groups:\n  - targets: [api]\n", "tools/.keep": ""})
+	cfg, err := Load(filepath.Join(dir, Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fstest.Chdir(t, dir)
+	if p := cfg.Path("api/v1"); p != "api/v1" {
+		t.Errorf("Path() = %s, expected api/v1", p)
+	}
+
+	fstest.Chdir(t, filepath.Join(dir, "tools"))
+	if p := cfg.Path("bin/protoc-gen-x"); p != "../bin/protoc-gen-x" {
+		t.Errorf("Path() = %s, expected ../bin/protoc-gen-x", p)
+	}
+
+	abs := filepath.ToSlash(filepath.Join(dir, "gen"))
+	if p := cfg.Path(abs); p != abs {
+		t.Errorf("Path() = %s, expected %s", p, abs)
+	}
+	if p := cfg.Path(""); p != "" {
+		t.Errorf("Path() = %s, expected empty", p)
+	}
+}
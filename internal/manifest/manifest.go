@@ -0,0 +1,284 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"sort"
+	"strings"
+)
+
+const (
+	// Filename is the path of manifest relative to the project root
+	Filename = ".protob/manifest.json"
+)
+
+// Entry represents the files generated by targets compiled together
+type Entry struct {
+	// targets compiled together, relative to the project root
+	Targets []string `json:"targets"`
+
+	// output directories of the plugins, relative to the project root
+	Dirs []string `json:"dirs,omitempty"`
+
+	// names of the plugins generated the outputs
+	Plugins []string `json:"plugins,omitempty"`
+
+	// files generated by the targets, relative to the project root
+	Outputs []string `json:"outputs"`
+}
+
+// Manifest represents the files generated by compiles of a project, only
+// files recorded in the manifest are removed as stale
+type Manifest struct {
+	Entries []*Entry `json:"entries"`
+
+	// root directory of the project
+	root string
+}
+
+// Root returns the root directory of the project
+func (m *Manifest) Root() string {
+	return m.root
+}
+
+// NewEntry create an entry from targets, output directories, plugins and
+// outputs, the paths are relative to working directory or absolute
+func (m *Manifest) NewEntry(targets, dirs, plugins, outputs []string) *Entry {
+	entry := &Entry{Targets: m.rels(targets), Dirs: m.rels(dirs), Plugins: append([]string{}, plugins...), Outputs: m.rels(outputs)}
+	sort.Strings(entry.Targets)
+	sort.Strings(entry.Dirs)
+	sort.Strings(entry.Plugins)
+	sort.Strings(entry.Outputs)
+	return entry
+}
+
+// Record replaces entries compiled again by new entries, and returns the
+// outputs no longer generated: outputs of the replaced entries not
+// generated again, and of the entries which targets are all removed, the
+// paths are relative to the project root. An entry is compiled again when
+// it has the same output directories and plugins of a new entry and any
+// of the targets, so the same targets compiled by other groups are kept
+func (m *Manifest) Record(entries []*Entry) []string {
+	compiled := make(map[string]map[string]bool)
+	for _, entry := range entries {
+		key := entry.key()
+		if compiled[key] == nil {
+			compiled[key] = make(map[string]bool)
+		}
+		for _, target := range entry.Targets {
+			compiled[key][target] = true
+		}
+	}
+
+	var kept, replaced []*Entry
+	for _, entry := range m.Entries {
+		if m.overlaps(entry, compiled[entry.key()]) || !m.alive(entry) {
+			replaced = append(replaced, entry)
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+
+	m.Entries = append(kept, entries...)
+	sort.Slice(m.Entries, func(i, j int) bool {
+		if a, b := first(m.Entries[i]), first(m.Entries[j]); a != b {
+			return a < b
+		}
+		return m.Entries[i].key() < m.Entries[j].key()
+	})
+	return m.stale(replaced)
+}
+
+// Prune removes the entries which targets are all removed, and the
+// entries in scopes which none of targets are in current, returns their
+// outputs which are not claimed by other entries. The scopes are paths
+// relative to working directory or absolute, all entries are in scope if
+// no scopes given
+func (m *Manifest) Prune(current []string, scopes ...string) []string {
+	targets := make(map[string]bool)
+	for _, target := range m.rels(current) {
+		targets[target] = true
+	}
+	scopes = m.rels(scopes)
+
+	var kept, pruned []*Entry
+	for _, entry := range m.Entries {
+		if m.alive(entry) && (!m.within(entry, scopes) || m.overlaps(entry, targets)) {
+			kept = append(kept, entry)
+		} else {
+			pruned = append(pruned, entry)
+		}
+	}
+
+	m.Entries = kept
+	return m.stale(pruned)
+}
+
+// Outputs returns all of the outputs recorded, relative to project root
+func (m *Manifest) Outputs() []string {
+	var outputs []string
+	for _, entry := range m.Entries {
+		outputs = append(outputs, entry.Outputs...)
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+// Remove deletes the outputs relative to project root, the outputs not
+// exist are ignored, returns the outputs removed
+func (m *Manifest) Remove(outputs []string) ([]string, error) {
+	var removed []string
+	for _, output := range outputs {
+		path := filepath.Join(m.root, filepath.FromSlash(output))
+		if ok, _ := fs.IsFile(path); !ok {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, output)
+	}
+	return removed, nil
+}
+
+// Save writes the manifest into the project root, the manifest file is
+// removed if no entries
+func (m *Manifest) Save() error {
+	path := filepath.Join(m.root, filepath.FromSlash(Filename))
+	if len(m.Entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(path, bytes.NewReader(append(content, '\n')), fs.RegularFilePerm)
+}
+
+// stale returns outputs of the entries which are not claimed by any
+// entry of the manifest
+func (m *Manifest) stale(entries []*Entry) []string {
+	claimed := make(map[string]bool)
+	for _, entry := range m.Entries {
+		for _, output := range entry.Outputs {
+			claimed[output] = true
+		}
+	}
+
+	var stale []string
+	for _, entry := range entries {
+		for _, output := range entry.Outputs {
+			if !claimed[output] {
+				claimed[output] = true
+				stale = append(stale, output)
+			}
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// overlaps reports whether any target of entry is in targets
+func (m *Manifest) overlaps(entry *Entry, targets map[string]bool) bool {
+	for _, target := range entry.Targets {
+		if targets[target] {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether all targets of entry are under any of scopes,
+// true if no scopes
+func (m *Manifest) within(entry *Entry, scopes []string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+
+	for _, target := range entry.Targets {
+		under := false
+		for _, scope := range scopes {
+			if scope == "." || target == scope || strings.HasPrefix(target, scope+"/") {
+				under = true
+				break
+			}
+		}
+		if !under {
+			return false
+		}
+	}
+	return true
+}
+
+// alive reports whether any target of entry still exists
+func (m *Manifest) alive(entry *Entry) bool {
+	for _, target := range entry.Targets {
+		if ok, _ := fs.IsFile(filepath.Join(m.root, filepath.FromSlash(target))); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rels returns the paths relative to the project root in slash
+func (m *Manifest) rels(paths []string) []string {
+	var rels []string
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+
+		if rel, err := filepath.Rel(m.root, abs); err == nil {
+			path = rel
+		}
+		rels = append(rels, filepath.ToSlash(path))
+	}
+	return rels
+}
+
+// key returns the output directories and plugins of entry, the entries
+// of the same key generate the same kind of outputs
+func (entry *Entry) key() string {
+	return strings.Join(entry.Dirs, "\x00") + "\x01" + strings.Join(entry.Plugins, "\x00")
+}
+
+// first returns the first target of entry
+func first(entry *Entry) string {
+	if len(entry.Targets) == 0 {
+		return ""
+	}
+	return entry.Targets[0]
+}
+
+// Load reads the manifest of project root, an empty manifest is returned
+// if not exists
+func Load(root string) (*Manifest, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{root: root}
+	content, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(Filename)))
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("manifest: %s", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,104 @@
+package manifest
+
+import (
+	"path/filepath"
+	"protob/pkg/os/fs/fstest"
+	"reflect"
+	"testing"
+)
+
+// load returns the empty manifest of a temporary root with the files
+func load(t *testing.T, files ...string) *Manifest {
+	m, err := Load(fstest.TempDir(t, fstest.Files(files...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// entry create an entry of paths relative to root of m
+func entry(m *Manifest, targets []string, dir string, plugins []string, outputs ...string) *Entry {
+	abs := func(paths []string) []string {
+		var result []string
+		for _, path := range paths {
+			result = append(result, filepath.Join(m.Root(), path))
+		}
+		return result
+	}
+	return m.NewEntry(abs(targets), abs([]string{dir}), plugins, abs(outputs))
+}
+
+func TestRecord(t *testing.T) {
+	m := load(t, "api/a.proto", "api/b.proto", "api/c.proto")
+	m.Record([]*Entry{
+		entry(m, []string{"api/a.proto", "api/b.proto"}, "gen/go", []string{"go"}, "gen/go/a.pb.go", "gen/go/b.pb.go"),
+		entry(m, []string{"api/a.proto", "api/b.proto"}, "gen/py", []string{"python"}, "gen/py/a_pb2.py", "gen/py/b_pb2.py"),
+		entry(m, []string{"api/c.proto"}, "gen/go", []string{"go"}, "gen/go/c.pb.go"),
+	})
+
+	tests := []struct {
+		name    string
+		entries []*Entry
+		stale   []string
+		outputs int
+	}{
+		{name: "other group kept",
+			entries: []*Entry{entry(m, []string{"api/a.proto", "api/b.proto"}, "gen/go", []string{"go"}, "gen/go/a.pb.go", "gen/go/b.pb.go")},
+			outputs: 5},
+		{name: "output changed",
+			entries: []*Entry{entry(m, []string{"api/a.proto", "api/b.proto"}, "gen/py", []string{"python"}, "gen/py/a_pb2.py")},
+			stale:   []string{"gen/py/b_pb2.py"}, outputs: 4},
+		{name: "regrouped",
+			entries: []*Entry{entry(m, []string{"api/b.proto", "api/c.proto"}, "gen/go", []string{"go"}, "gen/go/b.pb.go", "gen/go/c.pb.go")},
+			stale:   []string{"gen/go/a.pb.go"}, outputs: 3},
+	}
+
+	for _, test := range tests {
+		if stale := m.Record(test.entries); !reflect.DeepEqual(stale, test.stale) {
+			t.Errorf("%s: Record() = %v, expected %v", test.name, stale, test.stale)
+		}
+		if outputs := m.Outputs(); len(outputs) != test.outputs {
+			t.Errorf("%s: Outputs() = %v, expected %d outputs", test.name, outputs, test.outputs)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []string
+		scopes  []string
+		stale   []string
+	}{
+		{name: "all", stale: []string{"gen/a.pb.go", "gen/b.pb.go", "gen/c.pb.go", "gen/d.pb.go"}},
+		{name: "not current", current: []string{"api/a.proto", "other/c.proto"},
+			stale: []string{"gen/b.pb.go", "gen/d.pb.go"}},
+		{name: "in scopes", current: []string{"api/a.proto"}, scopes: []string{"api"},
+			stale: []string{"gen/b.pb.go", "gen/d.pb.go"}},
+		{name: "out of scopes", current: []string{"other/c.proto"}, scopes: []string{"other"},
+			stale: []string{"gen/d.pb.go"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := load(t, "api/a.proto", "api/b.proto", "other/c.proto")
+			m.Record([]*Entry{
+				entry(m, []string{"api/a.proto"}, "gen", []string{"go"}, "gen/a.pb.go"),
+				entry(m, []string{"api/b.proto"}, "gen", []string{"go"}, "gen/b.pb.go"),
+				entry(m, []string{"other/c.proto"}, "gen", []string{"go"}, "gen/c.pb.go"),
+				entry(m, []string{"removed/d.proto"}, "gen", []string{"go"}, "gen/d.pb.go"),
+			})
+
+			var current, scopes []string
+			for _, path := range test.current {
+				current = append(current, filepath.Join(m.Root(), path))
+			}
+			for _, path := range test.scopes {
+				scopes = append(scopes, filepath.Join(m.Root(), path))
+			}
+			if stale := m.Prune(current, scopes...); !reflect.DeepEqual(stale, test.stale) {
+				t.Errorf("Prune() = %v, expected %v", stale, test.stale)
+			}
+		})
+	}
+}
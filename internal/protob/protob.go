@@ -1,8 +1,11 @@
 package protob
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"protob/pkg/os/fs"
 	"protob/pkg/protobuf"
+	"protob/pkg/protobuf/gogo"
 
 	"github.com/mitchellh/go-homedir"
 )
@@ -19,6 +22,12 @@ func Dependency() string {
 	return fs.Join(Home(), "include")
 }
 
+// Includes returns include paths of the installed dependencies, the
+// well-known types and the gogoproto
+func Includes() []string {
+	return []string{Dependency(), fs.Join(Dependency(), gogo.Namespace)}
+}
+
 // Compiler returns path of the embedded compiler
 func Compiler() string {
 	return fs.Join(Home(), protobuf.CompilerExecutable)
@@ -28,3 +37,19 @@ func Compiler() string {
 func Temporary() string {
 	return fs.Join(Home(), ".temp")
 }
+
+// Cache returns path of the compile cache
+func Cache() string {
+	return fs.Join(Home(), "cache")
+}
+
+// Deps returns path of the remote dependencies cache
+func Deps() string {
+	return fs.Join(Home(), "deps")
+}
+
+// Modules returns path of the go modules include tree of the project
+func Modules(project string) string {
+	sum := sha256.Sum256([]byte(project))
+	return fs.Join(Home(), "modules", hex.EncodeToString(sum[:8]))
+}
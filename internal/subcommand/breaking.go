@@ -0,0 +1,241 @@
+package subcommand
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"protob/internal/config"
+	"protob/pkg/git"
+	"protob/pkg/logging"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf"
+	"protob/pkg/protobuf/breaking"
+	"protob/pkg/protobuf/target"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func Breaking() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "breaking --against <git-ref|descriptor.bin> [targets...]",
+		Short: "Detect breaking changes of Protobuf files",
+		Long: `Detect breaking changes of Protobuf files
+
+The current schema is compared to the baseline, which is a descriptor set
+file generated by protob descriptor, or a git ref of the repository. The
+targets of git ref are resolved the same as the working tree.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.PersistentFlags()
+			if err := checkBackend(flags); err != nil {
+				logging.Exit(exitConfigError, "breaking: %s", err)
+				return
+			}
+			compiler, _ := selectCompiler(flags)
+			if compiler == nil {
+				logging.Fatal("breaking: compiler not found or invalid")
+				return
+			}
+
+			format, _ := flags.GetString("error-format")
+			if err := checkErrorFormat(format); err != nil {
+				logging.Fatal("breaking: %s", err)
+				return
+			}
+
+			against, _ := flags.GetString("against")
+			if against == "" {
+				logging.Fatal("breaking: baseline required by --against")
+				return
+			}
+
+			current, err := buildBreakingSet(cmd.Context(), flags, compiler, args, format)
+			if err != nil {
+				logging.Fatal("breaking: %s", err)
+				return
+			}
+
+			previous, err := loadBaseline(cmd.Context(), flags, compiler, args, against, format)
+			if err != nil {
+				logging.Fatal("breaking: baseline: %s", err)
+				return
+			}
+
+			changes := breaking.Compare(previous, current)
+			if wireOnly, _ := flags.GetBool("wire-only"); wireOnly {
+				var wire []*breaking.Change
+				for _, change := range changes {
+					if change.Kind == breaking.KindWire {
+						wire = append(wire, change)
+					}
+				}
+				changes = wire
+			}
+
+			printChanges(changes, format)
+			if len(changes) != 0 {
+				logging.Fatal("breaking: %d incompatible changes against %s", len(changes), against)
+				return
+			}
+			logging.Success("no breaking changes against %s", against)
+		},
+	}
+
+	cmd.PersistentFlags().Bool("sys", false, "using system compiler")
+	cmd.PersistentFlags().String("backend", protobuf.BackendProtoc, "compiler backend: protoc, or native to compile in process without protoc")
+	cmd.PersistentFlags().StringP("config", "c", "", "path of the protob.yaml, lookup from working directory by default")
+	cmd.PersistentFlags().String("against", "", "git ref or descriptor set file of the baseline")
+	cmd.PersistentFlags().StringSlice("exclude", nil, "patterns of targets to exclude")
+	cmd.PersistentFlags().Bool("wire-only", false, "only report changes break the binary encoding or rpc calls")
+	cmd.PersistentFlags().String("error-format", errorFormatHuman, "format of the changes: human, gcc or json")
+	cmd.PersistentFlags().StringSliceP("proto_path", "I", nil, "transparent argument for protoc set dependencies")
+	cmd.PersistentFlags().Bool("gopath", false, "resolve imports from $GOPATH/src instead of go modules")
+
+	return cmd
+}
+
+// buildBreakingSet build descriptor set of targets, the failures are
+// printed if any build failed
+func buildBreakingSet(ctx context.Context, flags *pflag.FlagSet, compiler protobuf.Compiler, args []string, format string) (*descriptorpb.FileDescriptorSet, error) {
+	set, report, err := buildDescriptorSet(ctx, flags, compiler, args)
+	if err != nil {
+		return nil, err
+	} else if report.Failed() {
+		printFailures(report, format)
+		return nil, fmt.Errorf("%d of %d builds failed", len(report.Failures), len(report.Failures)+len(report.Succeeded))
+	}
+	return set, nil
+}
+
+// loadBaseline read descriptor set from file, or build descriptor set of
+// the targets in the tree at git ref
+func loadBaseline(ctx context.Context, flags *pflag.FlagSet, compiler protobuf.Compiler, args []string, against, format string) (*descriptorpb.FileDescriptorSet, error) {
+	if ok, _ := fs.IsFile(against); ok {
+		return protobuf.ReadDescriptorSet(against)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	root, prefix, err := git.Toplevel(wd)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is neither a descriptor set nor a git ref: %s", against, err)
+	}
+
+	commit, err := git.ResolveCommit(root, against)
+	if err != nil || commit == "" {
+		return nil, fmt.Errorf("'%s' is neither a descriptor set nor a git ref", against)
+	}
+
+	tree, err := ioutil.TempDir("", "protob-breaking")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tree) }()
+
+	paths, err := baselinePaths(flags, args, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := git.Export(root, commit, tree, paths...); err != nil {
+		return nil, err
+	}
+
+	// the configuration file in working tree is mapped into the exported tree
+	if path, _ := flags.GetString("config"); path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				_ = flags.Set("config", filepath.Join(tree, rel))
+				defer func() { _ = flags.Set("config", path) }()
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(tree, prefix), fs.DirectoryPerm); err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(filepath.Join(tree, prefix)); err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	return buildBreakingSet(ctx, flags, compiler, args, format)
+}
+
+// baselinePaths returns the paths relative to the repository root which
+// the baseline is built from: the configuration and ignore files, the
+// include paths and the roots of target patterns, so that files removed
+// from the working tree are still exported. No paths are returned to
+// export the whole tree when there are no targets to narrow it
+func baselinePaths(flags *pflag.FlagSet, args []string, root string) ([]string, error) {
+	var paths, patterns []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			patterns = append(patterns, arg)
+		}
+	}
+	includes, _ := flags.GetStringSlice("proto_path")
+	paths = append(paths, target.IgnoreFile)
+	paths = append(paths, includes...)
+
+	cfg, err := loadConfig(flags)
+	if err == nil {
+		paths = append(paths, fs.Join(cfg.Dir(), config.Filename), fs.Join(cfg.Dir(), target.IgnoreFile))
+		if len(patterns) == 0 {
+			for _, group := range cfg.Groups {
+				patterns = append(patterns, cfg.Paths(group.Targets)...)
+				paths = append(paths, cfg.Paths(group.Include)...)
+			}
+		}
+	} else if err != config.ErrNotFound {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	for _, pattern := range patterns {
+		paths = append(paths, target.Root(pattern))
+	}
+
+	var rels []string
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			rels = append(rels, fs.NormalizePath(rel))
+		}
+	}
+	return rels, nil
+}
+
+// printChanges print the breaking changes in format
+func printChanges(changes []*breaking.Change, format string) {
+	switch format {
+	case errorFormatJSON:
+		if changes == nil {
+			changes = make([]*breaking.Change, 0)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(changes)
+	case errorFormatGCC:
+		for _, change := range changes {
+			_, _ = fmt.Fprintln(os.Stderr, change)
+		}
+	default:
+		for _, change := range changes {
+			logging.Error("%s", change)
+		}
+	}
+}
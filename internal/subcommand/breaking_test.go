@@ -0,0 +1,66 @@
+package subcommand
+
+import (
+	"context"
+	"os"
+	"protob/pkg/os/fs/fstest"
+	"protob/pkg/protobuf"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLoadBaselineOfGitRef(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		args  []string
+	}{
+		{name: "targets", args: []string{"api/..."}},
+		{name: "config", files: map[string]string{"protob.yaml": "groups:\n  - targets: [api/...]\n"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := map[string]string{
+				"api/a.proto":   "syntax = \"proto3\";\npackage api;\nmessage A { string name = 1; int32 id = 2; }\n",
+				"api/b.proto":   "syntax = \"proto3\";\npackage api;\nimport \"a.proto\";\nmessage B { A a = 1; }\n",
+				"other/c.proto": "syntax = \"proto3\";\npackage other;\nmessage C {}\n",
+			}
+			for name, content := range test.files {
+				files[name] = content
+			}
+			repo := fstest.GitRepo(t, files)
+
+			// the working tree differs from the baseline
+			fstest.WriteFiles(t, repo, map[string]string{"api/a.proto": "syntax = \"proto3\";\npackage api;\nmessage A { string name = 1; }\n"})
+			if err := os.Remove(repo + "/api/b.proto"); err != nil {
+				t.Fatal(err)
+			}
+
+			fstest.Chdir(t, repo)
+
+			flags := Breaking().PersistentFlags()
+			set, err := loadBaseline(context.Background(), flags, protobuf.NewNativeCompiler(), test.args, "HEAD", errorFormatHuman)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			fields := 0
+			for _, file := range set.GetFile() {
+				names = append(names, file.GetName())
+				if file.GetName() == "a.proto" {
+					fields = len(file.GetMessageType()[0].GetField())
+				}
+			}
+			sort.Strings(names)
+			if expected := []string{"a.proto", "b.proto"}; !reflect.DeepEqual(names, expected) {
+				t.Errorf("loadBaseline() files = %v, expected %v", names, expected)
+			}
+			if fields != 2 {
+				t.Errorf("loadBaseline() a.proto has %d fields, expected 2 of the baseline", fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package subcommand
+
+import (
+	"os"
+	"protob/internal/build"
+	"protob/internal/config"
+	"protob/internal/manifest"
+	"protob/pkg/logging"
+	"protob/pkg/protobuf/target"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func Clean() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clean [targets...]",
+		Short: "Remove generated files no longer produced",
+		Long: `Remove generated files no longer produced
+
+The files generated by every compile are recorded in .protob/manifest.json of
+the project, outputs of targets not exist or not compiled any more are removed,
+files not generated by protob are never touched. Only outputs of the targets
+under the given paths are removed when targets given. The include trees of the
+go modules no longer required by go.mod are removed as well, or all of them
+with --all.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			m, err := manifest.Load(manifestRoot(cmd.PersistentFlags()))
+			if err != nil {
+				logging.Fatal("clean: %s", err)
+				return
+			}
+
+			all, _ := cmd.PersistentFlags().GetBool("all")
+			trees, err := staleModuleTrees(all)
+			if err != nil {
+				logging.Info("go modules unavailable, include trees kept: %s", err)
+			}
+
+			var stale []string
+			if all {
+				stale = m.Prune(nil)
+			} else {
+				groups, err := buildCompileGroups(cmd.PersistentFlags(), args, false)
+				if err != nil {
+					logging.Fatal("clean: %s", err)
+					return
+				}
+
+				var targets, scopes []string
+				for _, group := range groups {
+					targets = append(targets, group.targets...)
+				}
+				for _, arg := range args {
+					scopes = append(scopes, target.Root(arg))
+				}
+				stale = m.Prune(targets, scopes...)
+			}
+
+			if dryRun, _ := cmd.PersistentFlags().GetBool("dry-run"); dryRun {
+				for _, output := range append(stale, trees...) {
+					logging.Info("would remove %s", output)
+				}
+				return
+			}
+
+			removed, err := m.Remove(stale)
+			for _, output := range removed {
+				logging.Info("removed %s", output)
+			}
+			if err != nil {
+				logging.Fatal("clean: %s", err)
+				return
+			}
+
+			if err := m.Save(); err != nil {
+				logging.Fatal("clean: %s", err)
+				return
+			}
+
+			for _, tree := range trees {
+				if err := os.RemoveAll(tree); err != nil {
+					logging.Fatal("clean: %s", err)
+					return
+				}
+				logging.Info("removed include tree %s", tree)
+			}
+			logging.Success("clean completed, %d stale files removed", len(removed))
+		},
+	}
+
+	cmd.PersistentFlags().StringP("config", "c", "", "path of the protob.yaml, lookup from working directory by default")
+	cmd.PersistentFlags().StringSlice("exclude", nil, "patterns of targets to exclude")
+	cmd.PersistentFlags().Bool("all", false, "remove all generated files recorded")
+	cmd.PersistentFlags().Bool("dry-run", false, "print the files would be removed without removing")
+
+	return cmd
+}
+
+// manifestRoot returns the directory of configuration file, or working
+// directory if not found
+func manifestRoot(fs *pflag.FlagSet) string {
+	if cfg, err := loadConfig(fs); err == nil {
+		return cfg.Dir()
+	} else if err != config.ErrNotFound {
+		logging.Error("manifest: %s", err)
+	}
+
+	wd, _ := os.Getwd()
+	return wd
+}
+
+// recordOutputs record the outputs of compiled units into the manifest,
+// and remove the outputs no longer generated
+func recordOutputs(fs *pflag.FlagSet, report *build.Report) error {
+	m, err := manifest.Load(manifestRoot(fs))
+	if err != nil {
+		return err
+	}
+
+	var entries []*manifest.Entry
+	for _, unit := range append(append([]*build.Unit{}, report.Succeeded...), report.Cached...) {
+		entries = append(entries, newEntry(m, unit, report.Outputs[unit]))
+	}
+
+	removed, err := m.Remove(m.Record(entries))
+	for _, output := range removed {
+		logging.Info("removed stale output %s", output)
+	}
+	if err != nil {
+		return err
+	}
+	return m.Save()
+}
+
+// newEntry create the manifest entry of unit which generated outputs
+func newEntry(m *manifest.Manifest, unit *build.Unit, outputs []string) *manifest.Entry {
+	var plugins []string
+	for _, plugin := range unit.Runtime.Plugins() {
+		plugins = append(plugins, plugin.Name)
+	}
+	return m.NewEntry(unit.Targets, unit.Runtime.OutputDirs(unit.Targets), plugins, outputs)
+}
@@ -0,0 +1,93 @@
+package subcommand
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"protob/internal/build"
+	"protob/pkg/os/fs"
+	"protob/pkg/os/fs/fstest"
+	"protob/pkg/protobuf"
+	"testing"
+)
+
+// fakeCompiler returns a compiler which writes a .pb.go for every target
+// into the output of every plugin
+func fakeCompiler() *protobuf.FakeCompiler {
+	compiler := protobuf.NewFakeCompiler("libprotoc 3.15.0", protobuf.CapArguments)
+	compiler.CompileFunc = protobuf.FakeGenerate
+	return compiler
+}
+
+// compileProject compiles the targets with the fake compiler, and records
+// the outputs into the manifest
+func compileProject(t *testing.T, args ...string) {
+	flags := Compile().PersistentFlags()
+	units, err := buildCompileUnits(flags, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := build.Run(context.Background(), fakeCompiler(), units)
+	if report.Failed() || len(report.Succeeded) == 0 {
+		t.Fatalf("compile %v: %d failed, %d succeeded", args, len(report.Failures), len(report.Succeeded))
+	}
+	if err := recordOutputs(flags, report); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(root string)
+		args    []string
+		removed []string
+		kept    []string
+	}{
+		{name: "other targets kept", args: []string{"api/a"},
+			kept: []string{"api/a/a.pb.go", "api/b/b.pb.go", "other/c.pb.go"}},
+		{name: "removed targets", prepare: func(root string) {
+			_ = os.Remove(filepath.Join(root, "other/c.proto"))
+		}, args: []string{"api/a/a.proto"},
+			removed: []string{"other/c.pb.go"}, kept: []string{"api/a/a.pb.go", "api/b/b.pb.go"}},
+		{name: "excluded targets", args: []string{"api/...", "--exclude", "api/b"},
+			removed: []string{"api/b/b.pb.go"}, kept: []string{"api/a/a.pb.go", "other/c.pb.go"}},
+		{name: "all", args: []string{"--all"},
+			removed: []string{"api/a/a.pb.go", "api/b/b.pb.go", "other/c.pb.go"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := fstest.TempDir(t, map[string]string{
+				"api/a/a.proto":   "syntax = \"proto3\";\npackage a;\n",
+				"api/b/b.proto":   "syntax = \"proto3\";\npackage b;\n",
+				"other/c.proto":   "syntax = \"proto3\";\npackage c;\n",
+				"other/notes.txt": "not generated",
+			})
+
+			fstest.Chdir(t, root)
+			compileProject(t, "api/...", "other/...")
+			if test.prepare != nil {
+				test.prepare(root)
+			}
+
+			cmd := Clean()
+			cmd.SetArgs(test.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, file := range test.removed {
+				if ok, _ := fs.IsFile(filepath.Join(root, file)); ok {
+					t.Errorf("clean %v kept %s, expected removed", test.args, file)
+				}
+			}
+			for _, file := range append(test.kept, "other/notes.txt") {
+				if ok, _ := fs.IsFile(filepath.Join(root, file)); !ok {
+					t.Errorf("clean %v removed %s, expected kept", test.args, file)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,21 @@
 package subcommand
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"protob/internal/build"
+	"protob/internal/cache"
+	"protob/internal/config"
 	"protob/internal/protob"
 	"protob/pkg/logging"
 	"protob/pkg/protobuf"
+	"protob/pkg/protobuf/golang"
+	"protob/pkg/protobuf/target"
+	"runtime"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -11,76 +23,440 @@ import (
 
 func Compile() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "compile",
+		Use:   "compile [targets...]",
 		Short: "Compile Protobuf files",
+		Long: `Compile Protobuf files
+
+Targets can be protobuf files, directories, directories with '/...' to
+include all protobuf files recursively or glob patterns like 'api/**/*.proto',
+files matched by patterns in .protobignore or --exclude are skipped.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			compiler, err := protobuf.NewCompiler(protob.Compiler())
-			if sys, _ := cmd.PersistentFlags().GetBool("sys"); sys || err != nil {
-				compiler, _ = protobuf.NewSystemCompiler()
+			if err := checkBackend(cmd.PersistentFlags()); err != nil {
+				logging.Exit(exitConfigError, "compile: %s", err)
+				return
+			}
+			compiler, reason := selectCompiler(cmd.PersistentFlags())
+			dryRun, _ := cmd.PersistentFlags().GetBool("dry-run")
+			explain, _ := cmd.PersistentFlags().GetBool("explain")
+			if compiler == nil && !dryRun && !explain {
+				logging.Exit(exitCompilerMissing, "compile: compiler not found or invalid")
+				return
+			}
+
+			format, _ := cmd.PersistentFlags().GetString("error-format")
+			if err := checkErrorFormat(format); err != nil {
+				logging.Exit(exitConfigError, "compile: %s", err)
+				return
 			}
 
-			if compiler == nil {
-				logging.Fatal("compile: compiler not found or invalid")
+			if watch, _ := cmd.PersistentFlags().GetBool("watch"); watch && !dryRun && !explain {
+				watchAndCompile(cmd.Context(), cmd.PersistentFlags(), compiler, args)
 				return
 			}
 
-			runtime, targets := buildRuntimeAndTarget(cmd.PersistentFlags(), args)
-			for _, target := range targets {
-				if err := compiler.Compile(target, runtime); err != nil {
-					logging.Fatal("compile: build '%s' error: %s", target, err)
+			units, err := buildCompileUnits(cmd.PersistentFlags(), args)
+			if err != nil {
+				logging.Exit(exitConfigError, "compile: %s", err)
+				return
+			}
+
+			if dryRun || explain {
+				printDryRun(os.Stdout, compiler, reason, units, explain)
+				if compiler == nil {
+					logging.Exit(exitCompilerMissing, "compile: compiler not found or invalid")
 				}
+				return
+			}
+
+			keepGoing, _ := cmd.PersistentFlags().GetBool("keep-going")
+			report := build.Run(cmd.Context(), compiler, units, buildOptions(cmd.PersistentFlags())...)
+			if keepGoing && format != errorFormatJSON {
+				printSummary(os.Stdout, units, report)
+			} else if format == errorFormatJSON || report.Failed() {
+				printFailures(report, format)
+			}
+			if err := recordOutputs(cmd.PersistentFlags(), report); err != nil {
+				logging.Exit(exitManifestError, "compile: %s", err)
+				return
+			}
+			if cmd.Context().Err() != nil {
+				logging.Exit(exitInterrupted, "compile: interrupted, %d of %d builds skipped", len(report.Skipped), len(units))
 			}
-			logging.Success("build completed")
+			if report.Failed() {
+				logging.Exit(exitCompileFailed, "compile: %d of %d builds failed", len(report.Failures), len(units))
+			}
+			logging.Success("build completed, %d compiled, %d up to date", len(report.Succeeded), len(report.Cached))
 		},
 	}
 
-	cmd.PersistentFlags().Bool("sys", false, "using system compiler")
-	cmd.PersistentFlags().StringP("output", "o", "", "output directory")
+	compileFlags(cmd.PersistentFlags())
+	cmd.PersistentFlags().Bool("force", false, "compile all targets even if they are up to date")
+	cmd.PersistentFlags().BoolP("keep-going", "k", false, "compile all targets even if any failed, then print a summary")
+	cmd.PersistentFlags().Bool("explain", false, "print how the compiler, include paths and plugins are resolved without running")
+	cmd.PersistentFlags().BoolP("watch", "w", false, "watch targets and their imports, compile affected targets on change")
+	cmd.PersistentFlags().Duration("interval", 500*time.Millisecond, "interval of polling changes in watch mode")
 
-	cmd.PersistentFlags().Bool("fast", false, "enable gogo-fast extension")
-	cmd.PersistentFlags().Bool("faster", false, "enable gogo-faster extension")
-	cmd.PersistentFlags().Bool("slick", true, "enable gogo-slick extension")
-	cmd.PersistentFlags().Bool("grpc", false, "whether compile with grpc")
+	return cmd
+}
 
-	cmd.PersistentFlags().StringSliceP("proto_path", "I", nil, "transparent argument for protoc set dependencies")
-	cmd.PersistentFlags().Bool("source-relative", false, "transparent argument for protoc set source_relative")
+// compileFlags adds the flags shared by compile and verify, which select
+// the compiler, the targets and plugins and how the compiler runs
+func compileFlags(flags *pflag.FlagSet) {
+	flags.Bool("sys", false, "using system compiler")
+	flags.String("backend", protobuf.BackendProtoc, "compiler backend: protoc, or native to compile in process without protoc")
+	flags.StringP("config", "c", "", "path of the protob.yaml, lookup from working directory by default")
+	flags.StringP("output", "o", "", "output directory")
+	flags.StringSlice("exclude", nil, "patterns of targets to exclude")
+	flags.IntP("jobs", "j", 1, "number of compiler invocations run at the same time, 0 for the number of CPUs")
+	flags.Duration("timeout", 0, "maximum duration of each compiler invocation, 0 for no limit")
+	flags.String("error-format", errorFormatHuman, "format of the diagnostics: human, gcc or json")
+	flags.Bool("dry-run", false, "print the compiler command lines without running")
 
-	return cmd
+	flags.StringArray("plugin", nil, "plugin to generate code, in form of 'name:out=dir,key=value,...'")
+	flags.StringArray("lang", nil, "language built in protoc to generate code alongside plugins, in form of 'python:out=dir,key=value,...'")
+	flags.Bool("fast", false, "enable gogo-fast extension")
+	flags.Bool("faster", false, "enable gogo-faster extension")
+	flags.Bool("slick", true, "enable gogo-slick extension")
+	flags.Bool("golang", false, "enable protoc-gen-go and protoc-gen-go-grpc")
+	flags.String("go-module", "", "go module path removed from output path with golang, detected from go.mod of the output directory by default")
+	flags.Bool("grpc", false, "whether compile with grpc")
+
+	flags.StringSliceP("proto_path", "I", nil, "transparent argument for protoc set dependencies")
+	flags.Bool("source-relative", false, "transparent argument for protoc set source_relative")
+	flags.Bool("gopath", false, "resolve imports from $GOPATH/src instead of go modules")
+}
+
+// checkBackend returns error if the backend is not supported
+func checkBackend(fs *pflag.FlagSet) error {
+	switch backend, _ := fs.GetString("backend"); backend {
+	case "", protobuf.BackendProtoc, protobuf.BackendNative:
+		return nil
+	default:
+		return fmt.Errorf("unknown backend '%s', expected %s or %s", backend, protobuf.BackendProtoc, protobuf.BackendNative)
+	}
+}
+
+// selectCompiler returns the native backend if required, the embedded
+// compiler, or the system compiler when required or the embedded one is
+// invalid, with the reason of choice, the compiler is nil if not found
+func selectCompiler(fs *pflag.FlagSet) (protobuf.Compiler, string) {
+	switch backend, _ := fs.GetString("backend"); backend {
+	case protobuf.BackendNative:
+		return protobuf.NewNativeCompiler(), "native backend required by --backend"
+	case "", protobuf.BackendProtoc:
+	default:
+		return nil, fmt.Sprintf("unknown backend %s", backend)
+	}
+
+	compiler, err := protobuf.NewCompiler(protob.Compiler())
+	if sys, _ := fs.GetBool("sys"); sys {
+		return systemCompiler(), "system compiler required by --sys"
+	} else if err != nil {
+		return systemCompiler(), fmt.Sprintf("system compiler, the embedded %s is %s", protob.Compiler(), err)
+	}
+	return compiler, "embedded compiler"
+}
+
+// systemCompiler returns the compiler found in system path, or nil
+func systemCompiler() protobuf.Compiler {
+	if compiler, err := protobuf.NewSystemCompiler(); err == nil {
+		return compiler
+	}
+	return nil
+}
+
+// buildOptions returns options of the build from flags
+func buildOptions(fs *pflag.FlagSet) []build.Option {
+	force, _ := fs.GetBool("force")
+	keepGoing, _ := fs.GetBool("keep-going")
+
+	return append(runOptions(fs),
+		build.WithCache(cache.New(protob.Cache())),
+		build.WithForce(force),
+		build.WithKeepGoing(keepGoing),
+	)
+}
+
+// runOptions returns options of how the compiler runs from flags
+func runOptions(fs *pflag.FlagSet) []build.Option {
+	jobs, _ := fs.GetInt("jobs")
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	timeout, _ := fs.GetDuration("timeout")
+
+	return []build.Option{build.WithJobs(jobs), build.WithTimeout(timeout)}
+}
+
+// compileGroup represents a set of targets compiled with the same runtime
+type compileGroup struct {
+	runtime *protobuf.CompilerRuntime
+	targets []string
+
+	// go module path given by flag or config, detected by unit if empty
+	module string
+}
+
+// buildCompileUnits build compile groups and split them into units
+func buildCompileUnits(fs *pflag.FlagSet, args []string) ([]*build.Unit, error) {
+	groups, err := buildCompileGroups(fs, args, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []*build.Unit
+	for _, group := range groups {
+		grouped, err := build.NewUnits(group.runtime, group.targets)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, unit := range grouped {
+			if group.module != "" {
+				continue
+			}
+			if module := detectGoModule(unit.Runtime.OutputDir(unit.Targets)); module != "" {
+				unit.Runtime = unit.Runtime.Clone(protobuf.WithGoModule(module))
+			}
+		}
+		units = append(units, grouped...)
+	}
+	return units, nil
+}
+
+// buildCompileGroups build compile groups from targets on command line or
+// groups declared in configuration file, flags always override config values.
+// The include paths to resolve imports, the go modules and the fetched
+// dependencies, are prepared only if resolve
+func buildCompileGroups(fs *pflag.FlagSet, args []string, resolve bool) ([]*compileGroup, error) {
+	excludes, _ := fs.GetStringSlice("exclude")
+
+	runtime, patterns, err := buildRuntimeAndTarget(fs, &config.Group{}, args, resolve)
+	if err != nil {
+		return nil, err
+	} else if len(patterns) != 0 {
+		exclusion, err := target.LoadExclusion(".", excludes...)
+		if err != nil {
+			return nil, err
+		}
+
+		targets, err := target.Expand(patterns, exclusion)
+		if err != nil {
+			return nil, err
+		}
+
+		if resolve {
+			cfg, err := loadConfig(fs)
+			if err == nil {
+				remote, err := remoteIncludes(cfg)
+				if err != nil {
+					return nil, err
+				}
+				runtime = runtime.Clone(protobuf.WithRemoteDependencies(remote...))
+			} else if err != config.ErrNotFound {
+				return nil, err
+			}
+		}
+		return []*compileGroup{{runtime: runtime, targets: targets, module: goModule(fs, &config.Group{})}}, nil
+	}
+
+	cfg, err := loadConfig(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	var remote []string
+	if resolve {
+		if remote, err = remoteIncludes(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var groups []*compileGroup
+	for _, group := range cfg.Groups {
+		resolved := *group
+		resolved.Include = cfg.Paths(group.Include)
+		resolved.Output = cfg.Path(group.Output)
+		resolved.Plugins = nil
+		for _, plugin := range group.Plugins {
+			p := *plugin
+			p.Out = cfg.Path(plugin.Out)
+			p.Path = cfg.Path(plugin.Path)
+			resolved.Plugins = append(resolved.Plugins, &p)
+		}
+		resolved.Languages = nil
+		for _, language := range group.Languages {
+			l := *language
+			l.Out = cfg.Path(language.Out)
+			resolved.Languages = append(resolved.Languages, &l)
+		}
+
+		exclusion, err := target.LoadExclusion(cfg.Dir(), append(group.Exclude, excludes...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		targets, err := target.Expand(cfg.Paths(group.Targets), exclusion)
+		if err != nil {
+			return nil, err
+		}
+
+		runtime, _, err := buildRuntimeAndTarget(fs, &resolved, args, resolve)
+		if err != nil {
+			return nil, err
+		}
+		runtime = runtime.Clone(protobuf.WithRemoteDependencies(remote...))
+		groups = append(groups, &compileGroup{runtime: runtime, targets: targets, module: goModule(fs, &resolved)})
+	}
+
+	if len(groups) == 0 {
+		return nil, errors.New("no targets specified")
+	}
+	return groups, nil
 }
 
-// buildRuntimeAndTarget build compile runtime and split targets
-func buildRuntimeAndTarget(fs *pflag.FlagSet, args []string) (*protobuf.CompilerRuntime, []string) {
-	var options []protobuf.CompileOption
-	if grpc, err := fs.GetBool("grpc"); err == nil {
+// loadConfig load configuration file from flag or lookup from working directory
+func loadConfig(fs *pflag.FlagSet) (*config.Config, error) {
+	if path, err := fs.GetString("config"); err == nil && path != "" {
+		return config.Load(path)
+	}
+	return config.Lookup()
+}
+
+// buildRuntimeAndTarget build compile runtime and split targets, the
+// values of group will be overridden by flags which set explicitly, the go
+// modules are listed only if resolve
+func buildRuntimeAndTarget(fs *pflag.FlagSet, group *config.Group, args []string, resolve bool) (*protobuf.CompilerRuntime, []string, error) {
+	options := []protobuf.CompileOption{
+		protobuf.WithGrpc(group.Grpc),
+		protobuf.WithExtFast(group.Extension == "fast"),
+		protobuf.WithExtFaster(group.Extension == "faster"),
+		protobuf.WithExtSlick(group.Extension == "slick"),
+		protobuf.WithGolang(group.Extension == "golang"),
+		protobuf.WithDependencies(group.Include...),
+		protobuf.WithSourceRelative(group.SourceRelative),
+		protobuf.WithOutput(group.Output),
+		protobuf.WithPluginDirs(protob.Home()),
+		protobuf.WithManagedIncludes(protob.Includes()...),
+		protobuf.WithGoModules(goModulesInclude(fs, resolve)),
+	}
+
+	if specs, err := fs.GetStringArray("plugin"); err == nil && len(specs) != 0 {
+		for _, spec := range specs {
+			plugin, err := protobuf.ParsePlugin(spec)
+			if err != nil {
+				return nil, nil, err
+			}
+			options = append(options, protobuf.WithPlugins(plugin))
+		}
+	} else {
+		for _, plugin := range group.Plugins {
+			options = append(options, protobuf.WithPlugins(&protobuf.Plugin{
+				Name:       plugin.Name,
+				Parameters: plugin.Parameters,
+				Out:        plugin.Out,
+				Path:       plugin.Path,
+			}))
+		}
+	}
+
+	if specs, err := fs.GetStringArray("lang"); err == nil && len(specs) != 0 {
+		for _, spec := range specs {
+			language, err := protobuf.ParseLanguage(spec)
+			if err != nil {
+				return nil, nil, err
+			}
+			options = append(options, protobuf.WithLanguages(language))
+		}
+	} else {
+		for _, l := range group.Languages {
+			language, err := protobuf.ParseLanguage(l.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+			language.Out, language.Parameters = l.Out, l.Parameters
+			options = append(options, protobuf.WithLanguages(language))
+		}
+	}
+
+	if grpc, err := fs.GetBool("grpc"); err == nil && fs.Changed("grpc") {
 		options = append(options, protobuf.WithGrpc(grpc))
 	}
-	if fast, err := fs.GetBool("fast"); err == nil {
+	if fast, err := fs.GetBool("fast"); err == nil && fs.Changed("fast") {
 		options = append(options, protobuf.WithExtFast(fast))
 	}
-	if faster, err := fs.GetBool("faster"); err == nil {
+	if faster, err := fs.GetBool("faster"); err == nil && fs.Changed("faster") {
 		options = append(options, protobuf.WithExtFaster(faster))
 	}
-	if slick, err := fs.GetBool("slick"); err == nil {
-		options = append(options, protobuf.WithExtSlick(slick))
+	if slick, err := fs.GetBool("slick"); err == nil && fs.Changed("slick") {
+		if slick {
+			options = append(options, protobuf.WithExtSlick(slick))
+		} else {
+			options = append(options, protobuf.WithoutExtSlick())
+		}
 	}
 	if deps, err := fs.GetStringSlice("proto_path"); err == nil && deps != nil {
 		options = append(options, protobuf.WithDependencies(deps...))
 	}
-	if relative, err := fs.GetBool("source_relative"); err == nil {
+	if golang, err := fs.GetBool("golang"); err == nil && fs.Changed("golang") {
+		options = append(options, protobuf.WithGolang(golang))
+	}
+	if relative, err := fs.GetBool("source-relative"); err == nil && fs.Changed("source-relative") {
 		options = append(options, protobuf.WithSourceRelative(relative))
 	}
 	if output, err := fs.GetString("output"); err == nil && output != "" {
 		options = append(options, protobuf.WithOutput(output))
 	}
+	options = append(options, protobuf.WithGoModule(goModule(fs, group)))
 
-	var targets []string
+	var targets, arguments []string
 	for _, arg := range args {
 		if arg[0] == '-' {
-			options = append(options, protobuf.WithAddArguments(arg))
+			arguments = append(arguments, arg)
 		} else {
 			targets = append(targets, arg)
 		}
 	}
+	options = append(options, protobuf.WithAddArguments(arguments...))
+
+	runtime := protobuf.NewCompileRuntime(options...)
+	if len(runtime.Plugins()) == 0 && len(arguments) == 0 {
+		return nil, nil, errors.New("--slick=false leaves nothing to generate, set plugins, languages or an extension")
+	}
+	return runtime, targets, nil
+}
+
+// goModule returns the go module path from flag or group, the module is
+// removed from output path with golang
+func goModule(fs *pflag.FlagSet, group *config.Group) string {
+	if module, err := fs.GetString("go-module"); err == nil && fs.Changed("go-module") {
+		return module
+	}
+	return group.Module
+}
+
+// detectGoModule returns the import path of the output directory by the
+// go.mod found from it up to the root, e.g. example.com/svc/gen for output
+// gen in module example.com/svc, or empty if not in a module
+func detectGoModule(output string) string {
+	root, err := golang.FindModuleRoot(output)
+	if err != nil {
+		return ""
+	}
+
+	module, err := golang.ModulePath(root)
+	if err != nil {
+		return ""
+	}
 
-	return protobuf.NewCompileRuntime(options...), targets
+	abs, err := filepath.Abs(output)
+	if err != nil {
+		return ""
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == "." {
+		return module
+	}
+	return path.Join(module, filepath.ToSlash(rel))
 }
@@ -0,0 +1,208 @@
+package subcommand
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"protob/internal/config"
+	"protob/internal/protob"
+	"protob/pkg/deps"
+	"protob/pkg/logging"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf/graph"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func Deps() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deps",
+		Short: "Inspect dependencies of Protobuf files",
+	}
+
+	cmd.AddCommand(depsFetch())
+	cmd.AddCommand(depsGraph())
+
+	return cmd
+}
+
+func depsFetch() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch remote dependencies and pin their versions",
+		Long: `Fetch remote dependencies and pin their versions
+
+The dependencies declared in protob.yaml are fetched into ~/.protob/deps at
+the versions pinned in protob.lock, the dependencies not locked or changed
+are fetched at the latest version of ref, and their exact commits or hashes
+are written into protob.lock. Use --update to fetch all of them again.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := loadConfig(cmd.PersistentFlags())
+			if err != nil {
+				logging.Exit(exitConfigError, "deps: %s", err)
+				return
+			}
+
+			lock, err := deps.LoadLock(lockPath(cfg))
+			if err != nil {
+				logging.Exit(exitConfigError, "deps: %s", err)
+				return
+			}
+
+			update, _ := cmd.PersistentFlags().GetBool("update")
+			fetcher := deps.NewFetcher(protob.Deps(), httpClient)
+			fetched := &deps.Lock{}
+			for _, d := range cfg.Deps {
+				dependency := dependencyOf(d)
+
+				var locked *deps.Locked
+				if !update {
+					locked = lock.Lookup(dependency)
+				}
+
+				version, include, err := fetcher.Fetch(cmd.Context(), dependency, locked)
+				if err != nil {
+					logging.Fatal("%s", err)
+					return
+				}
+				logging.Info("%s %s -> %s", version.Name, versionOf(version), include)
+				fetched.Dependencies = append(fetched.Dependencies, version)
+			}
+
+			if len(fetched.Dependencies) == 0 {
+				if err := os.Remove(lockPath(cfg)); err != nil && !os.IsNotExist(err) {
+					logging.Fatal("deps: %s", err)
+				}
+				logging.Success("no dependencies declared")
+				return
+			}
+
+			if err := fetched.Save(lockPath(cfg)); err != nil {
+				logging.Fatal("deps: %s", err)
+				return
+			}
+			logging.Success("%d dependencies fetched, pinned in %s", len(fetched.Dependencies), deps.LockFilename)
+		},
+	}
+
+	cmd.PersistentFlags().StringP("config", "c", "", "path of the protob.yaml, lookup from working directory by default")
+	cmd.PersistentFlags().Bool("update", false, "fetch the latest version of all dependencies ignoring protob.lock")
+
+	return cmd
+}
+
+func depsGraph() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph [targets...]",
+		Short: "Print the import graph of Protobuf files",
+		Long: `Print the import graph of Protobuf files
+
+Imports of targets are resolved transitively from the include paths the
+same as compile, the import cycles and missing imports are reported.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			format, _ := cmd.PersistentFlags().GetString("format")
+
+			g, err := buildGraph(cmd.PersistentFlags(), args)
+			if err != nil {
+				logging.Fatal("deps: %s", err)
+				return
+			}
+
+			switch format {
+			case "json":
+				err = g.WriteJSON(os.Stdout)
+			case "dot":
+				err = g.WriteDot(os.Stdout)
+			default:
+				err = g.WriteText(os.Stdout)
+			}
+			if err != nil {
+				logging.Fatal("deps: %s", err)
+				return
+			}
+
+			if cycles, missing := g.Cycles(), g.Missing(); len(cycles) != 0 || len(missing) != 0 {
+				logging.Fatal("deps: %d import cycles, %d missing imports", len(cycles), len(missing))
+			}
+		},
+	}
+
+	cmd.PersistentFlags().StringP("config", "c", "", "path of the protob.yaml, lookup from working directory by default")
+	cmd.PersistentFlags().StringSlice("exclude", nil, "patterns of targets to exclude")
+	cmd.PersistentFlags().StringP("format", "f", "text", "format of the graph: text, json or dot")
+	cmd.PersistentFlags().StringSliceP("proto_path", "I", nil, "transparent argument for protoc set dependencies")
+	cmd.PersistentFlags().Bool("gopath", false, "resolve imports from $GOPATH/src instead of go modules")
+
+	return cmd
+}
+
+// buildGraph build import graph of targets in every compile unit, the
+// imports resolved from the include paths compile uses for the unit
+func buildGraph(flags *pflag.FlagSet, args []string) (*graph.Graph, error) {
+	switch format, _ := flags.GetString("format"); format {
+	case "text", "json", "dot":
+	default:
+		return nil, errors.New("unknown graph format '" + format + "'")
+	}
+
+	units, err := buildCompileUnits(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	g := graph.New()
+	for _, unit := range units {
+		if err := g.Add(unit.Runtime.Includes(unit.Targets), unit.Targets); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// remoteIncludes returns include paths of the dependencies declared in
+// configuration at the versions locked, which must be fetched already
+func remoteIncludes(cfg *config.Config) ([]string, error) {
+	if len(cfg.Deps) == 0 {
+		return nil, nil
+	}
+
+	lock, err := deps.LoadLock(lockPath(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	var includes []string
+	fetcher := deps.NewFetcher(protob.Deps(), httpClient)
+	for _, d := range cfg.Deps {
+		locked := lock.Lookup(dependencyOf(d))
+		if locked == nil {
+			return nil, fmt.Errorf("deps: %s is not locked, run protob deps fetch", d.Name)
+		}
+
+		include, ok := fetcher.Include(locked)
+		if !ok {
+			return nil, fmt.Errorf("deps: %s is not fetched, run protob deps fetch", d.Name)
+		}
+		includes = append(includes, include)
+	}
+	return includes, nil
+}
+
+// dependencyOf returns the dependency declared in configuration
+func dependencyOf(d *config.Dependency) *deps.Dependency {
+	return &deps.Dependency{Name: d.Name, Git: d.Git, Ref: d.Ref, URL: d.URL, Subdir: d.Subdir}
+}
+
+// versionOf returns the short commit or hash of the locked version
+func versionOf(locked *deps.Locked) string {
+	if locked.Commit != "" {
+		return "commit " + locked.Commit[:12]
+	}
+	return "sha256 " + locked.SHA256[:12]
+}
+
+// lockPath returns path of the lock file next to configuration file
+func lockPath(cfg *config.Config) string {
+	return fs.Join(cfg.Dir(), deps.LockFilename)
+}
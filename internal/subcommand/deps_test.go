@@ -0,0 +1,40 @@
+package subcommand
+
+import (
+	"protob/pkg/os/fs/fstest"
+	"reflect"
+	"testing"
+)
+
+func TestBuildGraph(t *testing.T) {
+	fstest.Chdir(t, fstest.TempDir(t, map[string]string{
+		"api/a/a.proto": "syntax = \"proto3\";\nimport \"b.proto\";\n",
+		"api/b/b.proto": "syntax = \"proto3\";\n",
+	}))
+
+	// a and b are compiled in separate units, b.proto is never in the
+	// include paths of a
+	g, err := buildGraph(depsGraph().PersistentFlags(), []string{"api/..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := g.Missing(); !reflect.DeepEqual(missing, []string{"b.proto"}) {
+		t.Errorf("buildGraph() missing = %v, expected [b.proto]", missing)
+	}
+}
+
+func TestBuildCompileGroupsRemoteIncludes(t *testing.T) {
+	fstest.Chdir(t, fstest.TempDir(t, map[string]string{
+		"api/a.proto": "syntax = \"proto3\";\n",
+		"protob.yaml": "groups:\n  - name: api\n    targets: [api]\ndeps:\n  - name: googleapis\n    git: https://github.com/googleapis/googleapis\n",
+	}))
+
+	for _, args := range [][]string{nil, {"api"}} {
+		if _, err := buildCompileGroups(Lint().PersistentFlags(), args, false); err != nil {
+			t.Errorf("buildCompileGroups(%v) = %v, expected deps not required without resolving imports", args, err)
+		}
+		if _, err := buildCompileGroups(Compile().PersistentFlags(), args, true); err == nil {
+			t.Errorf("buildCompileGroups(%v) = nil, expected error of deps not locked", args)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package subcommand
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"protob/internal/build"
+	"protob/pkg/logging"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func Descriptor() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "descriptor [targets...]",
+		Short: "Generate FileDescriptorSet of Protobuf files",
+		Long: `Generate FileDescriptorSet of Protobuf files
+
+Targets and include paths are resolved the same as compile, the groups
+declared in protob.yaml are merged into one descriptor set.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkBackend(cmd.PersistentFlags()); err != nil {
+				logging.Exit(exitConfigError, "descriptor: %s", err)
+				return
+			}
+			compiler, _ := selectCompiler(cmd.PersistentFlags())
+			if compiler == nil {
+				logging.Exit(exitCompilerMissing, "descriptor: compiler not found or invalid")
+				return
+			}
+
+			out, _ := cmd.PersistentFlags().GetString("out")
+			if out == "" {
+				logging.Exit(exitConfigError, "descriptor: output file required")
+				return
+			}
+
+			format, _ := cmd.PersistentFlags().GetString("error-format")
+			if err := checkErrorFormat(format); err != nil {
+				logging.Exit(exitConfigError, "descriptor: %s", err)
+				return
+			}
+
+			set, report, err := buildDescriptorSet(cmd.Context(), cmd.PersistentFlags(), compiler, args)
+			if err != nil {
+				logging.Exit(exitConfigError, "descriptor: %s", err)
+				return
+			} else if report.Failed() {
+				printFailures(report, format)
+				logging.Exit(exitCompileFailed, "descriptor: %d of %d builds failed", len(report.Failures), len(report.Failures)+len(report.Succeeded))
+				return
+			}
+
+			content, err := proto.MarshalOptions{Deterministic: true}.Marshal(set)
+			if err == nil {
+				err = fs.WriteFile(out, bytes.NewReader(content), fs.RegularFilePerm)
+			}
+			if err != nil {
+				logging.Fatal("descriptor: %s", err)
+				return
+			}
+			logging.Success("descriptor set of %d files written into %s", len(set.GetFile()), out)
+		},
+	}
+
+	cmd.PersistentFlags().Bool("sys", false, "using system compiler")
+	cmd.PersistentFlags().String("backend", protobuf.BackendProtoc, "compiler backend: protoc, or native to compile in process without protoc")
+	cmd.PersistentFlags().StringP("config", "c", "", "path of the protob.yaml, lookup from working directory by default")
+	cmd.PersistentFlags().StringP("out", "o", "", "output file of the descriptor set")
+	cmd.PersistentFlags().StringSlice("exclude", nil, "patterns of targets to exclude")
+	cmd.PersistentFlags().String("error-format", errorFormatHuman, "format of the diagnostics: human, gcc or json")
+	cmd.PersistentFlags().Bool("include_imports", false, "include all dependencies of targets in the set")
+	cmd.PersistentFlags().Bool("include_source_info", false, "retain source code info in the set")
+	cmd.PersistentFlags().StringSliceP("proto_path", "I", nil, "transparent argument for protoc set dependencies")
+	cmd.PersistentFlags().Bool("gopath", false, "resolve imports from $GOPATH/src instead of go modules")
+
+	return cmd
+}
+
+// buildDescriptorSet compile descriptor set of every compile group, then
+// merge them into one descriptor set
+func buildDescriptorSet(ctx context.Context, flags *pflag.FlagSet, compiler protobuf.Compiler, args []string) (*descriptorpb.FileDescriptorSet, *build.Report, error) {
+	groups, err := buildCompileGroups(flags, args, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stage, err := ioutil.TempDir("", "protob-descriptor")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = os.RemoveAll(stage) }()
+
+	includeImports, _ := flags.GetBool("include_imports")
+	includeSourceInfo, _ := flags.GetBool("include_source_info")
+
+	report := &build.Report{}
+	var sets []*descriptorpb.FileDescriptorSet
+	for i, group := range groups {
+		out := fs.Join(stage, strconv.Itoa(i)+".bin")
+		unit := &build.Unit{
+			Runtime: group.runtime.Clone(protobuf.WithoutPlugins(), protobuf.WithDescriptorSet(out, includeImports, includeSourceInfo)),
+			Targets: group.targets,
+		}
+
+		if err := compiler.Compile(ctx, unit.Targets, unit.Runtime); err != nil {
+			report.Failures = append(report.Failures, &build.Failure{Unit: unit, Err: err})
+			continue
+		}
+		report.Succeeded = append(report.Succeeded, unit)
+
+		set, err := protobuf.ReadDescriptorSet(out)
+		if err != nil {
+			return nil, nil, err
+		}
+		sets = append(sets, set)
+	}
+
+	if len(sets) == 0 && !report.Failed() {
+		return nil, nil, errors.New("no targets specified")
+	}
+	merged, err := protobuf.MergeDescriptorSets(sets...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return merged, report, nil
+}
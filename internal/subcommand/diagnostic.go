@@ -0,0 +1,116 @@
+package subcommand
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"protob/internal/build"
+	"protob/pkg/logging"
+	"protob/pkg/protobuf"
+	"text/tabwriter"
+)
+
+const (
+	// errorFormatHuman prints diagnostics grouped by targets with colors
+	errorFormatHuman = "human"
+	// errorFormatGCC prints one diagnostic per line: file:line:column: severity: message
+	errorFormatGCC = "gcc"
+	// errorFormatJSON prints all diagnostics as a json array into stdout
+	errorFormatJSON = "json"
+)
+
+const (
+	// exitCompileFailed is the exit code when any target failed to compile
+	exitCompileFailed = 2
+	// exitConfigError is the exit code of invalid flags, configuration or targets
+	exitConfigError = 3
+	// exitCompilerMissing is the exit code when the compiler is not found or invalid
+	exitCompilerMissing = 4
+	// exitManifestError is the exit code when the outputs are generated but
+	// unable to record in the manifest
+	exitManifestError = 5
+	// exitInterrupted is the exit code when interrupted by signal
+	exitInterrupted = 130
+)
+
+// checkErrorFormat returns error if the format is not supported
+func checkErrorFormat(format string) error {
+	switch format {
+	case errorFormatHuman, errorFormatGCC, errorFormatJSON:
+		return nil
+	}
+	return errors.New("unknown error format '" + format + "'")
+}
+
+// diagnosticsOf returns diagnostics of the error returned by compiler
+func diagnosticsOf(err error) []*protobuf.Diagnostic {
+	if compileErr, ok := err.(*protobuf.CompileError); ok && len(compileErr.Diagnostics) != 0 {
+		return compileErr.Diagnostics
+	}
+	return []*protobuf.Diagnostic{{Severity: protobuf.SeverityError, Message: err.Error()}}
+}
+
+// printFailures print every failure of the build report in format
+func printFailures(report *build.Report, format string) {
+	switch format {
+	case errorFormatJSON:
+		diagnostics := make([]*protobuf.Diagnostic, 0)
+		for _, failure := range report.Failures {
+			diagnostics = append(diagnostics, diagnosticsOf(failure.Err)...)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(diagnostics)
+	case errorFormatGCC:
+		for _, failure := range report.Failures {
+			for _, diagnostic := range diagnosticsOf(failure.Err) {
+				_, _ = fmt.Fprintln(os.Stderr, diagnostic)
+			}
+		}
+	default:
+		for _, failure := range report.Failures {
+			logging.Error("build '%s' error", failure.Unit)
+			for _, diagnostic := range diagnosticsOf(failure.Err) {
+				logging.Error("  %s", diagnostic)
+			}
+		}
+		for _, unit := range report.Skipped {
+			logging.Error("build '%s' skipped", unit)
+		}
+	}
+}
+
+// printSummary print a table of units in order with their status, and the
+// diagnostics of failed units
+func printSummary(w io.Writer, units []*build.Unit, report *build.Report) {
+	status := make(map[*build.Unit]string)
+	for _, unit := range report.Succeeded {
+		status[unit] = "ok"
+	}
+	for _, unit := range report.Cached {
+		status[unit] = "cached"
+	}
+	for _, unit := range report.Skipped {
+		status[unit] = "skipped"
+	}
+	failures := make(map[*build.Unit]error)
+	for _, failure := range report.Failures {
+		status[failure.Unit] = "failed"
+		failures[failure.Unit] = failure.Err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STATUS\tTARGETS")
+	for _, unit := range units {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", status[unit], unit)
+		if err, ok := failures[unit]; ok {
+			for _, diagnostic := range diagnosticsOf(err) {
+				_, _ = fmt.Fprintf(tw, "\t  %s\n", diagnostic)
+			}
+		}
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,72 @@
+package subcommand
+
+import (
+	"fmt"
+	"io"
+	"protob/internal/build"
+	"protob/pkg/protobuf"
+	"strings"
+)
+
+// printDryRun print the command line of every unit without running, and
+// how the compiler, include paths and plugins are resolved when explain
+func printDryRun(w io.Writer, compiler protobuf.Compiler, reason string, units []*build.Unit, explain bool) {
+	if explain && compiler == nil {
+		_, _ = fmt.Fprintf(w, "compiler: not found\n")
+		_, _ = fmt.Fprintf(w, "  selected: %s\n", reason)
+	} else if explain {
+		_, _ = fmt.Fprintf(w, "compiler: %s (%s)\n", compilerPath(compiler), compiler.Version())
+		_, _ = fmt.Fprintf(w, "  selected: %s\n", reason)
+		_, _ = fmt.Fprintf(w, "  capabilities: %s\n", compiler.Capabilities())
+	}
+
+	for _, unit := range units {
+		args := append([]string{compilerPath(compiler)}, unit.Runtime.Build(unit.Targets)...)
+		if !explain {
+			_, _ = fmt.Fprintln(w, shellJoin(args))
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "\nunit: %s\n", unit)
+		for _, include := range unit.Runtime.IncludePaths(unit.Targets) {
+			_, _ = fmt.Fprintf(w, "  include: %s (%s)\n", include.Path, include.Origin)
+		}
+		for _, plugin := range unit.Runtime.Plugins() {
+			path, err := unit.Runtime.PluginPath(plugin)
+			if plugin.Builtin {
+				path = "built-in"
+			} else if err != nil {
+				path = err.Error()
+			}
+			_, _ = fmt.Fprintf(w, "  plugin: %s (%s) -> %s\n", plugin, path, unit.Runtime.PluginOutputDir(plugin, unit.Targets))
+		}
+		_, _ = fmt.Fprintf(w, "  command: %s\n", shellJoin(args))
+	}
+}
+
+// compilerPath returns path of the compiler executable, name of the
+// backend compiles in process, or protoc if no compiler found
+func compilerPath(compiler protobuf.Compiler) string {
+	switch c := compiler.(type) {
+	case nil:
+		return "protoc"
+	case *protobuf.ProtocCompiler:
+		return c.Path()
+	case *protobuf.NativeCompiler:
+		return protobuf.BackendNative
+	}
+	return fmt.Sprintf("%T", compiler)
+}
+
+// shellJoin joins arguments into a command line, the arguments with
+// special characters are quoted
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,103 @@
+package subcommand
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"protob/pkg/diff"
+	"protob/pkg/logging"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf/format"
+
+	"github.com/spf13/cobra"
+)
+
+func Format() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "format [targets...]",
+		Short: "Format Protobuf files in canonical layout",
+		Long: `Format Protobuf files in canonical layout
+
+Targets are resolved the same as compile and rewritten in place, unless
+--check or --diff is set, then the files are never touched.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.PersistentFlags()
+			check, _ := flags.GetBool("check")
+			showDiff, _ := flags.GetBool("diff")
+
+			groups, err := buildCompileGroups(flags, args, false)
+			if err != nil {
+				logging.Fatal("format: %s", err)
+				return
+			}
+
+			var failed, changed int
+			formatted := make(map[string]bool)
+			for _, group := range groups {
+				for _, target := range group.targets {
+					if formatted[target] {
+						continue
+					}
+					formatted[target] = true
+
+					ok, err := formatTarget(target, check || showDiff, showDiff)
+					if err != nil {
+						logging.Error("%s", err)
+						failed++
+					} else if !ok {
+						changed++
+						if check && !showDiff {
+							fmt.Println(target)
+						}
+					}
+				}
+			}
+
+			switch {
+			case failed != 0:
+				logging.Fatal("format: %d files unable to format", failed)
+			case check && changed != 0:
+				logging.Fatal("format: %d of %d files not formatted", changed, len(formatted))
+			case !check && !showDiff:
+				logging.Success("format completed, %d of %d files changed", changed, len(formatted))
+			}
+		},
+	}
+
+	cmd.PersistentFlags().StringP("config", "c", "", "path of the protob.yaml, lookup from working directory by default")
+	cmd.PersistentFlags().StringSlice("exclude", nil, "patterns of targets to exclude")
+	cmd.PersistentFlags().Bool("check", false, "list files not formatted and exit with error, without rewriting")
+	cmd.PersistentFlags().Bool("diff", false, "print the diff of formatting, without rewriting")
+
+	return cmd
+}
+
+// formatTarget formats the target and reports whether it is formatted
+// already, the file is rewritten unless dryRun
+func formatTarget(target string, dryRun, showDiff bool) (bool, error) {
+	content, err := ioutil.ReadFile(target)
+	if err != nil {
+		return false, err
+	}
+
+	out, err := format.Source(target, content)
+	if err != nil {
+		return false, err
+	} else if bytes.Equal(content, out) {
+		return true, nil
+	}
+
+	if showDiff {
+		fmt.Print(diff.Unified("a/"+target, "b/"+target, string(content), string(out)))
+	}
+	if dryRun {
+		return false, nil
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return false, err
+	}
+	return false, fs.WriteFile(target, bytes.NewReader(out), info.Mode().Perm())
+}
@@ -0,0 +1,100 @@
+package subcommand
+
+import (
+	"os"
+	"protob/internal/protob"
+	"protob/pkg/logging"
+	"protob/pkg/protobuf/golang"
+	"sync"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	// modulesOnce make sure the go modules are listed once per process
+	modulesOnce sync.Once
+
+	// modulesRoot is the include tree of the go modules
+	modulesRoot string
+)
+
+// goModulesInclude returns the include tree of the modules required by
+// go.mod of the working directory, empty if no go.mod found or the go
+// modules disabled, then $GOPATH/src is used instead. The modules are
+// listed only if resolve, commands never resolving imports such as lint
+// and format pass false
+func goModulesInclude(fs *pflag.FlagSet, resolve bool) string {
+	if !resolve {
+		return ""
+	} else if gopath, _ := fs.GetBool("gopath"); gopath {
+		return ""
+	}
+
+	modulesOnce.Do(func() {
+		root, err := linkGoModules()
+		if err == golang.ErrNoModule {
+			return
+		} else if err != nil {
+			logging.Info("go modules unavailable, fallback to $GOPATH/src: %s", err)
+			return
+		}
+		modulesRoot = root
+	})
+	return modulesRoot
+}
+
+// linkGoModules links the modules required by go.mod of the working
+// directory into the include tree of the project
+func linkGoModules() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	project, err := golang.FindModuleRoot(wd)
+	if err != nil {
+		return "", err
+	}
+
+	modules, err := golang.ListModules(project)
+	if err != nil {
+		return "", err
+	}
+	return golang.LinkModules(protob.Modules(project), modules)
+}
+
+// staleModuleTrees returns the include trees of the project not matching
+// go.mod any more, or all of them if all
+func staleModuleTrees(all bool) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := golang.FindModuleRoot(wd)
+	if err == golang.ErrNoModule {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var current string
+	if !all {
+		if current, err = linkGoModules(); err != nil {
+			return nil, err
+		}
+	}
+
+	trees, err := golang.ModuleTrees(protob.Modules(project))
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for _, tree := range trees {
+		if tree != current {
+			stale = append(stale, tree)
+		}
+	}
+	return stale, nil
+}
@@ -10,11 +10,13 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"protob/internal/protob"
 	"protob/pkg/logging"
 	"protob/pkg/os/fs"
 	"protob/pkg/protobuf"
 	"protob/pkg/protobuf/gogo"
+	"protob/pkg/protobuf/golang"
 	"protob/pkg/zip"
 	"runtime"
 	"strings"
@@ -44,12 +46,18 @@ func Install() *cobra.Command {
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			if err := installProtobuf(cmd.Context()); err == nil {
-				_ = installGoGoProtobuf(cmd.Context())
+				if err = installGoGoProtobuf(cmd.Context()); err == nil {
+					if golang, _ := cmd.PersistentFlags().GetBool("golang"); golang {
+						proxy, _ := cmd.PersistentFlags().GetString("proxy")
+						_ = installGolangProtobuf(cmd.Context(), proxy)
+					}
+				}
 			}
 		},
 	}
 
 	cmd.PersistentFlags().String("proxy", "", "proxy for http request")
+	cmd.PersistentFlags().Bool("golang", false, "install protoc-gen-go and protoc-gen-go-grpc plugins")
 
 	return cmd
 }
@@ -119,6 +127,21 @@ func installGoGoProtobuf(ctx context.Context) (err error) {
 	return
 }
 
+// installGolangProtobuf install protoc-gen-go and protoc-gen-go-grpc plugins
+func installGolangProtobuf(ctx context.Context, proxy string) (err error) {
+	logging.Loading("install golang protobuf plugins", func(bar *logging.Bar) {
+		defer func() { bar.Error(err) }()
+
+		bar.Text(fmt.Sprintf("compiling golang plugins into %s", protob.Home()))
+		if err = compileGolangPlugins(ctx, protob.Home(), proxy); err != nil {
+			return
+		}
+
+		bar.Success("golang plugins installed")
+	})
+	return
+}
+
 // latestRelease retrieve latest release info from github
 func latestRelease(ctx context.Context, owner, repo string) (*github.RepositoryRelease, error) {
 	releases, _, err := githubClient.Repositories.ListReleases(ctx, owner, repo, &github.ListOptions{PerPage: 1})
@@ -217,3 +240,30 @@ func compileGoGoExtensions(source string, dst string) (err error) {
 
 	return nil
 }
+
+// compileGolangPlugins compile protoc-gen-go and protoc-gen-go-grpc from
+// their latest modules into dst
+func compileGolangPlugins(ctx context.Context, dst string, proxy string) (err error) {
+	var compiler string
+	if compiler, err = exec.LookPath("go"); err != nil {
+		return errors.New("install: go compiler not found")
+	}
+
+	if dst, err = filepath.Abs(dst); err != nil {
+		return err
+	}
+
+	for _, executable := range []string{"protoc-gen-go", "protoc-gen-go-grpc"} {
+		cmd := exec.CommandContext(ctx, compiler, "install", golang.Plugins[executable]+"@latest")
+		cmd.Env = append(os.Environ(), "GOBIN="+dst)
+		if proxy != "" {
+			cmd.Env = append(cmd.Env, "HTTP_PROXY="+proxy, "HTTPS_PROXY="+proxy)
+		}
+
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("install: build %s error: %s", executable, strings.TrimSpace(string(out)))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package subcommand
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"protob/internal/config"
+	"protob/pkg/logging"
+	"protob/pkg/protobuf/lint"
+	"protob/pkg/protobuf/parser"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func Lint() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint [targets...]",
+		Short: "Check style and API rules of Protobuf files",
+		Long: `Check style and API rules of Protobuf files
+
+Targets are resolved the same as compile, rules are enabled or disabled
+by the lint section of protob.yaml and then the flags overriding it, each
+disabled first and then enabled.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.PersistentFlags()
+			format, _ := flags.GetString("error-format")
+			if err := checkErrorFormat(format); err != nil {
+				logging.Fatal("lint: %s", err)
+				return
+			}
+
+			linter, err := buildLinter(flags)
+			if err != nil {
+				logging.Fatal("lint: %s", err)
+				return
+			}
+
+			if list, _ := flags.GetBool("list"); list {
+				for _, rule := range linter.Rules() {
+					fmt.Printf("%-30s %s\n", rule.Name, rule.Description)
+				}
+				return
+			}
+
+			problems, err := lintTargets(flags, linter, args)
+			if err != nil {
+				logging.Fatal("lint: %s", err)
+				return
+			}
+
+			printProblems(problems, format)
+			if len(problems) != 0 {
+				logging.Fatal("lint: %d problems found", len(problems))
+			}
+		},
+	}
+
+	cmd.PersistentFlags().StringP("config", "c", "", "path of the protob.yaml, lookup from working directory by default")
+	cmd.PersistentFlags().StringSlice("exclude", nil, "patterns of targets to exclude")
+	cmd.PersistentFlags().StringSlice("enable", nil, "rules to enable overriding the config, 'all' for every rule")
+	cmd.PersistentFlags().StringSlice("disable", nil, "rules to disable overriding the config, 'all' for every rule")
+	cmd.PersistentFlags().Bool("list", false, "list the enabled rules")
+	cmd.PersistentFlags().String("error-format", errorFormatHuman, "format of the problems: human, gcc or json")
+
+	return cmd
+}
+
+// buildLinter creates linter by rules of configuration file, then the
+// flags, so the flags override the configuration file
+func buildLinter(flags *pflag.FlagSet) (*lint.Linter, error) {
+	var selections []lint.Selection
+	if cfg, err := loadConfig(flags); err == nil {
+		selections = append(selections, lint.Selection{Enable: cfg.Lint.Enable, Disable: cfg.Lint.Disable})
+	} else if err != config.ErrNotFound {
+		return nil, err
+	}
+
+	enable, _ := flags.GetStringSlice("enable")
+	disable, _ := flags.GetStringSlice("disable")
+	return lint.New(append(selections, lint.Selection{Enable: enable, Disable: disable})...)
+}
+
+// lintTargets parses and checks targets of every compile group, the
+// syntax errors are reported as problems
+func lintTargets(flags *pflag.FlagSet, linter *lint.Linter, args []string) ([]*lint.Problem, error) {
+	groups, err := buildCompileGroups(flags, args, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []*lint.Problem
+	linted := make(map[string]bool)
+	for _, group := range groups {
+		for _, target := range group.targets {
+			if linted[target] {
+				continue
+			}
+			linted[target] = true
+
+			file, err := parser.ParseFile(target)
+			if parseErr, ok := err.(*parser.Error); ok {
+				problems = append(problems, &lint.Problem{
+					File:    parseErr.File,
+					Line:    parseErr.Pos.Line,
+					Column:  parseErr.Pos.Column,
+					Rule:    "syntax",
+					Message: parseErr.Message,
+				})
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			problems = append(problems, linter.Lint(file)...)
+		}
+	}
+	return problems, nil
+}
+
+// printProblems print the lint problems in format
+func printProblems(problems []*lint.Problem, format string) {
+	switch format {
+	case errorFormatJSON:
+		if problems == nil {
+			problems = make([]*lint.Problem, 0)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(problems)
+	case errorFormatGCC:
+		for _, problem := range problems {
+			_, _ = fmt.Fprintln(os.Stderr, problem)
+		}
+	default:
+		for _, problem := range problems {
+			logging.Error("%s", problem)
+		}
+	}
+}
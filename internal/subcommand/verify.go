@@ -0,0 +1,233 @@
+package subcommand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"protob/internal/build"
+	"protob/internal/manifest"
+	"protob/pkg/diff"
+	"protob/pkg/logging"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// driftStale represents the generated file differs from the committed
+	driftStale = "stale"
+	// driftMissing represents the generated file is not committed
+	driftMissing = "missing"
+	// driftExtra represents the committed file is no longer generated
+	driftExtra = "extra"
+)
+
+// drift represents a committed file not matched the generated one
+type drift struct {
+	kind string
+	path string
+	diff string
+}
+
+func Verify() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify [targets...]",
+		Short: "Check generated files are up to date",
+		Long: `Check generated files are up to date
+
+Targets are compiled into a temporary directory the same as compile, and the
+generated files are compared with the files in the output directories. Files
+stale, missing or no longer generated are listed with a diff, and exits with
+error on any difference, the working tree is never touched.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkBackend(cmd.PersistentFlags()); err != nil {
+				logging.Exit(exitConfigError, "verify: %s", err)
+				return
+			}
+			compiler, reason := selectCompiler(cmd.PersistentFlags())
+			dryRun, _ := cmd.PersistentFlags().GetBool("dry-run")
+			if compiler == nil && !dryRun {
+				logging.Exit(exitCompilerMissing, "verify: compiler not found or invalid")
+				return
+			}
+
+			format, _ := cmd.PersistentFlags().GetString("error-format")
+			if err := checkErrorFormat(format); err != nil {
+				logging.Exit(exitConfigError, "verify: %s", err)
+				return
+			}
+
+			units, err := buildCompileUnits(cmd.PersistentFlags(), args)
+			if err != nil {
+				logging.Exit(exitConfigError, "verify: %s", err)
+				return
+			}
+
+			if dryRun {
+				printDryRun(os.Stdout, compiler, reason, units, false)
+				if compiler == nil {
+					logging.Exit(exitCompilerMissing, "verify: compiler not found or invalid")
+				}
+				return
+			}
+
+			drifts, generated, report, err := verifyUnits(cmd.Context(), cmd.PersistentFlags(), compiler, units)
+			if err != nil {
+				logging.Fatal("verify: %s", err)
+				return
+			} else if report.Failed() {
+				printFailures(report, format)
+				logging.Exit(exitCompileFailed, "verify: %d of %d builds failed", len(report.Failures), len(units))
+				return
+			}
+
+			for _, d := range drifts {
+				fmt.Printf("%s: %s\n", d.kind, d.path)
+				fmt.Print(d.diff)
+			}
+			if len(drifts) != 0 {
+				logging.Fatal("verify: %d generated files out of date", len(drifts))
+				return
+			}
+			logging.Success("verify completed, %d generated files up to date", generated)
+		},
+	}
+
+	compileFlags(cmd.PersistentFlags())
+
+	return cmd
+}
+
+// verifyUnits compile units into a temporary directory and compare the
+// generated files with the files in output directories, the outputs
+// recorded in the manifest but not generated any more are extra
+func verifyUnits(ctx context.Context, flags *pflag.FlagSet, compiler protobuf.Compiler, units []*build.Unit) ([]*drift, int, *build.Report, error) {
+	stage, err := ioutil.TempDir("", "protob-verify")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer func() { _ = os.RemoveAll(stage) }()
+
+	options := append(runOptions(flags), build.WithStage(stage), build.WithKeepGoing(true))
+	report := build.Run(ctx, compiler, units, options...)
+	if report.Failed() {
+		return nil, 0, report, nil
+	}
+
+	var drifts []*drift
+	generated := make(map[string]bool)
+	outputs := make(map[*build.Unit][]string)
+	for _, unit := range report.Succeeded {
+		for output, dir := range report.Staging[unit] {
+			compared, files, err := compareOutputs(dir, output)
+			if err != nil {
+				return nil, 0, nil, err
+			}
+			drifts = append(drifts, compared...)
+			for _, file := range files {
+				generated[file] = true
+			}
+			outputs[unit] = append(outputs[unit], files...)
+		}
+	}
+
+	extras, err := extraOutputs(flags, report.Succeeded, outputs, generated)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	for _, extra := range extras {
+		drifts = append(drifts, &drift{kind: driftExtra, path: displayPath(extra)})
+	}
+
+	sort.Slice(drifts, func(i, j int) bool {
+		return drifts[i].path < drifts[j].path
+	})
+	return drifts, len(generated), report, nil
+}
+
+// compareOutputs compare files in stage with the files in the output
+// directory, returns the drifts and absolute paths of the compared files
+func compareOutputs(stage, output string) ([]*drift, []string, error) {
+	var drifts []*drift
+	var generated []string
+	err := filepath.Walk(stage, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(stage, path)
+		if err != nil {
+			return err
+		}
+
+		dst, err := filepath.Abs(filepath.Join(output, rel))
+		if err != nil {
+			return err
+		}
+		generated = append(generated, fs.NormalizePath(dst))
+
+		expected, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		name := displayPath(dst)
+		actual, err := ioutil.ReadFile(dst)
+		switch {
+		case os.IsNotExist(err):
+			drifts = append(drifts, &drift{kind: driftMissing, path: name})
+		case err != nil:
+			return err
+		case !bytes.Equal(actual, expected):
+			drifts = append(drifts, &drift{
+				kind: driftStale,
+				path: name,
+				diff: diff.Unified("a/"+name, "b/"+name, string(actual), string(expected)),
+			})
+		}
+		return nil
+	})
+	return drifts, generated, err
+}
+
+// extraOutputs returns the files recorded in the manifest but not
+// generated any more, the outputs replaced by outputs of the units or of
+// the removed targets, the files not recorded are never extra
+func extraOutputs(flags *pflag.FlagSet, units []*build.Unit, outputs map[*build.Unit][]string, generated map[string]bool) ([]string, error) {
+	m, err := manifest.Load(manifestRoot(flags))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*manifest.Entry
+	for _, unit := range units {
+		entries = append(entries, newEntry(m, unit, outputs[unit]))
+	}
+
+	var extras []string
+	for _, output := range m.Record(entries) {
+		if path := fs.Join(m.Root(), output); !generated[path] {
+			if ok, _ := fs.IsFile(path); ok {
+				extras = append(extras, path)
+			}
+		}
+	}
+	return extras, nil
+}
+
+// displayPath returns the path relative to working directory if possible
+func displayPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
@@ -0,0 +1,55 @@
+package subcommand
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"protob/pkg/os/fs/fstest"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyUnits(t *testing.T) {
+	root := fstest.TempDir(t, map[string]string{
+		"api/a/a.proto": "syntax = \"proto3\";\npackage a;\n",
+		"api/b/b.proto": "syntax = \"proto3\";\npackage b;\n",
+		"api/c/c.proto": "syntax = \"proto3\";\npackage c;\n",
+	})
+
+	fstest.Chdir(t, root)
+
+	compileProject(t, "api/...")
+	fstest.WriteFiles(t, root, map[string]string{
+		"api/a/a.pb.go":     "changed",
+		"api/a/doc.pb.go":   "hand written",
+		"api/a/__init__.py": "",
+	})
+	for _, file := range []string{"api/b/b.proto", "api/c/c.pb.go"} {
+		if err := os.Remove(filepath.Join(root, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	flags := Verify().PersistentFlags()
+	_ = flags.Set("jobs", "2")
+	units, err := buildCompileUnits(flags, []string{"api/..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drifts, generated, report, err := verifyUnits(context.Background(), flags, fakeCompiler(), units)
+	if err != nil {
+		t.Fatal(err)
+	} else if report.Failed() {
+		t.Fatalf("verifyUnits() %d failed", len(report.Failures))
+	}
+
+	var got []string
+	for _, d := range drifts {
+		got = append(got, d.kind+" "+d.path)
+	}
+	expected := []string{"stale api/a/a.pb.go", "extra api/b/b.pb.go", "missing api/c/c.pb.go"}
+	if !reflect.DeepEqual(got, expected) || generated != 2 {
+		t.Errorf("verifyUnits() = %v, %d generated, expected %v, 2 generated", got, generated, expected)
+	}
+}
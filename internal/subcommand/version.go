@@ -45,10 +45,10 @@ func Version(version, revision, buildTime string) *cobra.Command {
 			}
 
 			if compiler, err := protobuf.NewSystemCompiler(); err == nil {
-				data["SysCompilerVersion"] = compiler.Version
+				data["SysCompilerVersion"] = compiler.Version()
 			}
 			if compiler, err := protobuf.NewCompiler(protob.Compiler()); err == nil {
-				data["EmbeddedCompilerVersion"] = compiler.Version
+				data["EmbeddedCompilerVersion"] = compiler.Version()
 			}
 
 			tpl, _ := template.New("version").Parse(versionTemplate)
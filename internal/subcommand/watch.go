@@ -0,0 +1,189 @@
+package subcommand
+
+import (
+	"context"
+	"path/filepath"
+	"protob/internal/build"
+	"protob/pkg/logging"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// watchedUnit represents an unit and the files it depends on
+type watchedUnit struct {
+	unit  *build.Unit
+	files map[string]bool
+}
+
+// watchAndCompile compile all targets, then polling the targets and their
+// imports, compile only the affected units on change until ctx is done
+func watchAndCompile(ctx context.Context, flags *pflag.FlagSet, compiler protobuf.Compiler, args []string) {
+	interval, _ := flags.GetDuration("interval")
+	if interval <= 0 {
+		logging.Exit(exitConfigError, "compile: interval must be positive, got %s", interval)
+		return
+	}
+	options := buildOptions(flags)
+
+	watched, paths, err := buildWatchedUnits(flags, args)
+	if err != nil {
+		logging.Exit(exitConfigError, "compile: %s", err)
+		return
+	}
+
+	var units []*build.Unit
+	for _, w := range watched {
+		units = append(units, w.unit)
+	}
+	format, _ := flags.GetString("error-format")
+	printWatchReport(flags, build.Run(ctx, compiler, units, options...), format)
+
+	poller := fs.NewPoller()
+	poller.Poll(paths)
+	logging.Info("watching %d files for changes", len(paths))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed := poller.Poll(paths)
+		if len(changed) == 0 && !newTargets(flags, args, watched) {
+			continue
+		}
+
+		previous := watched
+		if watched, paths, err = buildWatchedUnits(flags, args); err != nil {
+			logging.Error("compile: %s", err)
+			continue
+		}
+		poller.Poll(paths)
+
+		if affected := affectedUnits(previous, watched, changed); len(affected) != 0 {
+			for _, unit := range affected {
+				logging.Info("changed: %s", unit)
+			}
+			printWatchReport(flags, build.Run(ctx, compiler, affected, options...), format)
+		}
+	}
+}
+
+// buildWatchedUnits build units and collect the paths to watch: the
+// targets, their resolved imports and the directories of targets
+func buildWatchedUnits(flags *pflag.FlagSet, args []string) ([]*watchedUnit, []string, error) {
+	units, err := buildCompileUnits(flags, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var watched []*watchedUnit
+	seen := make(map[string]bool)
+	var paths []string
+	for _, unit := range units {
+		imports, _, err := protobuf.Imports(unit.Runtime.Includes(unit.Targets), unit.Targets)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		w := &watchedUnit{unit: unit, files: make(map[string]bool)}
+		for _, file := range append(append([]string{}, unit.Targets...), imports...) {
+			w.files[file] = true
+			for _, path := range []string{file, filepath.Dir(file)} {
+				if path = watchPath(path); !seen[path] {
+					seen[path] = true
+					paths = append(paths, path)
+				}
+			}
+		}
+		watched = append(watched, w)
+	}
+	return watched, paths, nil
+}
+
+// newTargets reports whether the patterns expand to any target not
+// watched yet, such as files in new subdirectories of a recursive pattern
+func newTargets(flags *pflag.FlagSet, args []string, watched []*watchedUnit) bool {
+	groups, err := buildCompileGroups(flags, args, false)
+	if err != nil {
+		return false
+	}
+
+	known := make(map[string]bool)
+	for _, w := range watched {
+		for file := range w.files {
+			known[watchPath(file)] = true
+		}
+	}
+	for _, group := range groups {
+		for _, target := range group.targets {
+			if !known[watchPath(target)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// affectedUnits returns units which depend on any changed file, and units
+// not exists before, such as new files added into directory
+func affectedUnits(previous, current []*watchedUnit, changed []string) []*build.Unit {
+	known := make(map[string]bool)
+	for _, w := range previous {
+		known[w.unit.String()] = true
+	}
+
+	var affected []*build.Unit
+	for _, w := range current {
+		if !known[w.unit.String()] {
+			affected = append(affected, w.unit)
+			continue
+		}
+
+		for file := range w.files {
+			if containsPath(changed, watchPath(file)) {
+				affected = append(affected, w.unit)
+				break
+			}
+		}
+	}
+	return affected
+}
+
+// watchPath returns the normalized absolute path for watching
+func watchPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return fs.NormalizePath(abs)
+	}
+	return fs.NormalizePath(path)
+}
+
+// containsPath reports whether path is in paths
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// printWatchReport record outputs and print the build report without
+// exiting, outputs of the succeeded units are recorded even if any failed
+func printWatchReport(flags *pflag.FlagSet, report *build.Report, format string) {
+	if err := recordOutputs(flags, report); err != nil {
+		logging.Error("compile: %s", err)
+	}
+	if report.Failed() {
+		printFailures(report, format)
+		logging.Error("compile: %d builds failed", len(report.Failures))
+		return
+	}
+	logging.Success("build completed, %d compiled, %d up to date", len(report.Succeeded), len(report.Cached))
+}
@@ -0,0 +1,26 @@
+package subcommand
+
+import (
+	"protob/pkg/os/fs/fstest"
+	"testing"
+)
+
+func TestNewTargets(t *testing.T) {
+	root := fstest.TempDir(t, map[string]string{"api/a/a.proto": "syntax = \"proto3\";\npackage a;\n"})
+	fstest.Chdir(t, root)
+
+	flags := Compile().PersistentFlags()
+	args := []string{"api/..."}
+	watched, _, err := buildWatchedUnits(flags, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newTargets(flags, args, watched) {
+		t.Errorf("newTargets() = true, expected no new targets")
+	}
+
+	fstest.WriteFiles(t, root, map[string]string{"api/b/c/c.proto": "syntax = \"proto3\";\npackage c;\n"})
+	if !newTargets(flags, args, watched) {
+		t.Errorf("newTargets() = false, expected api/b/c/c.proto found in the new subdirectory")
+	}
+}
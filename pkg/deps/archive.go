@@ -0,0 +1,79 @@
+package deps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"protob/pkg/zip"
+	"strings"
+)
+
+// extract writes files of the zip archive, the tarball or the gzipped
+// tarball into dir, the format is detected from content
+func extract(content []byte, dir string) error {
+	switch {
+	case bytes.HasPrefix(content, []byte("PK\x03\x04")):
+		return zip.VisitFiles(content, func(file *zip.File) error {
+			path, err := archivePath(dir, file.Name)
+			if err != nil {
+				return err
+			}
+			return zip.AsReader(file, func(reader io.Reader) error {
+				return fs.WriteFile(path, reader, fs.RegularFilePerm)
+			})
+		})
+	case bytes.HasPrefix(content, []byte{0x1f, 0x8b}):
+		rd, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rd.Close() }()
+		return extractTar(rd, dir)
+	default:
+		return extractTar(bytes.NewReader(content), dir)
+	}
+}
+
+// extractTar writes regular files of the tarball into dir
+func extractTar(r io.Reader, dir string) error {
+	rd := tar.NewReader(r)
+	for {
+		header, err := rd.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("invalid archive: %s", err)
+		}
+
+		path, err := archivePath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, fs.DirectoryPerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := fs.WriteFile(path, rd, fs.RegularFilePerm); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// archivePath returns path of the file in archive extracted into dir,
+// the names out of dir are rejected
+func archivePath(dir, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("illegal path '%s' in archive", name)
+	}
+	return filepath.Join(dir, cleaned), nil
+}
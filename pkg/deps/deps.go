@@ -0,0 +1,66 @@
+package deps
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// remoteHead is the ref of the default branch fetched from remote
+	remoteHead = "refs/remote/HEAD"
+)
+
+// Dependency represents protobuf files from a git repository or an
+// archive on remote
+type Dependency struct {
+	// name of the dependency, unique in project
+	Name string
+
+	// url of the git repository
+	Git string
+
+	// branch, tag or commit of the git repository, HEAD by default
+	Ref string
+
+	// url of the tarball or zip archive
+	URL string
+
+	// directory in the source used as include path, the root by default
+	Subdir string
+}
+
+// Validate returns error if the source of dependency is not exactly one
+// of git repository or archive
+func (d *Dependency) Validate() error {
+	switch {
+	case d.Name == "":
+		return errors.New("deps: dependency without name")
+	case d.Git == "" && d.URL == "":
+		return fmt.Errorf("deps: %s: either git or url required", d.Name)
+	case d.Git != "" && d.URL != "":
+		return fmt.Errorf("deps: %s: git and url are exclusive", d.Name)
+	case d.URL != "" && d.Ref != "":
+		return fmt.Errorf("deps: %s: ref is only for git", d.Name)
+	case path.IsAbs(d.subdir()) || d.subdir() == ".." || strings.HasPrefix(d.subdir(), "../"):
+		return fmt.Errorf("deps: %s: subdir must be relative", d.Name)
+	}
+	return nil
+}
+
+// ref returns the ref of git repository, HEAD by default
+func (d *Dependency) ref() string {
+	if d.Ref == "" {
+		return "HEAD"
+	}
+	return d.Ref
+}
+
+// subdir returns the cleaned subdir in slash, empty for the root
+func (d *Dependency) subdir() string {
+	if subdir := path.Clean(strings.ReplaceAll(d.Subdir, "\\", "/")); subdir != "." {
+		return strings.TrimSuffix(subdir, "/")
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package deps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"protob/pkg/os/fs/fstest"
+	"testing"
+)
+
+func TestFetchGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	repo := fstest.TempDir(t, map[string]string{"proto/acme/v1/acme.proto": "syntax = \"proto3\";\n"})
+	gitRun(t, repo, "init", "--quiet")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "--quiet", "-m", "first")
+	gitRun(t, repo, "tag", "v1")
+
+	fetcher := NewFetcher(fstest.TempDir(t, nil), http.DefaultClient)
+	dependency := &Dependency{Name: "acme", Git: "file://" + filepath.ToSlash(repo), Ref: "v1", Subdir: "proto/"}
+	locked, include, err := fetcher.Fetch(context.Background(), dependency, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked.Commit) != 40 || locked.Subdir != "proto" {
+		t.Errorf("Fetch() = %+v, expected commit and subdir pinned", locked)
+	}
+	if _, err := os.Stat(filepath.Join(include, "acme/v1/acme.proto")); err != nil {
+		t.Errorf("Fetch() include %s without acme/v1/acme.proto", include)
+	}
+
+	fstest.WriteFiles(t, repo, map[string]string{"proto/acme/v1/acme.proto": "syntax = \"proto2\";\n"})
+	gitRun(t, repo, "commit", "--quiet", "-am", "second")
+	gitRun(t, repo, "tag", "-f", "v1")
+
+	lock := &Lock{Dependencies: []*Locked{locked}}
+	pinned, _, err := fetcher.Fetch(context.Background(), dependency, lock.Lookup(dependency))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned.Commit != locked.Commit {
+		t.Errorf("Fetch() = %s, expected the locked commit %s", pinned.Commit, locked.Commit)
+	}
+
+	latest, include, err := fetcher.Fetch(context.Background(), &Dependency{Name: "acme", Git: dependency.Git, Subdir: "proto"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(filepath.Join(include, "acme/v1/acme.proto"))
+	if latest.Commit == locked.Commit || string(content) != "syntax = \"proto2\";\n" {
+		t.Errorf("Fetch() = %s, expected the latest commit of HEAD", latest.Commit)
+	}
+
+	if _, _, err := fetcher.Fetch(context.Background(), &Dependency{Name: "acme", Git: dependency.Git, Subdir: "missing"}, nil); err == nil {
+		t.Errorf("Fetch() expected error of subdir not found")
+	}
+}
+
+func TestFetchArchive(t *testing.T) {
+	archive := tarball(t, map[string]string{"acme-1.0/acme/acme.proto": "syntax = \"proto3\";\n"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/acme-1.0.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(fstest.TempDir(t, nil), server.Client())
+	dependency := &Dependency{Name: "acme", URL: server.URL + "/acme-1.0.tar.gz", Subdir: "acme-1.0"}
+	locked, include, err := fetcher.Fetch(context.Background(), dependency, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked.SHA256) != 64 {
+		t.Errorf("Fetch() = %+v, expected sha256 pinned", locked)
+	}
+	if _, err := os.Stat(filepath.Join(include, "acme/acme.proto")); err != nil {
+		t.Errorf("Fetch() include %s without acme/acme.proto", include)
+	}
+
+	other := NewFetcher(fstest.TempDir(t, nil), server.Client())
+	if _, _, err := other.Fetch(context.Background(), dependency, &Locked{SHA256: "0000"}); err == nil {
+		t.Errorf("Fetch() expected error of checksum mismatch")
+	}
+	if _, _, err := other.Fetch(context.Background(), &Dependency{Name: "acme", URL: server.URL + "/missing.zip"}, nil); err == nil {
+		t.Errorf("Fetch() expected error of not found")
+	}
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(fstest.TempDir(t, nil), LockFilename)
+	lock, err := LoadLock(path)
+	if err != nil || len(lock.Dependencies) != 0 {
+		t.Fatalf("LoadLock() = %+v, %v, expected empty lock", lock, err)
+	}
+
+	dependency := &Dependency{Name: "acme", Git: "https://example.com/acme.git", Ref: "v1"}
+	lock.Dependencies = append(lock.Dependencies, &Locked{Name: "acme", Git: dependency.Git, Ref: "v1", Commit: "abc"})
+	if err := lock.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if lock, err = LoadLock(path); err != nil {
+		t.Fatal(err)
+	}
+	if locked := lock.Lookup(dependency); locked == nil || locked.Commit != "abc" {
+		t.Errorf("Lookup() = %+v, expected the locked commit", locked)
+	}
+
+	dependency.Ref = "v2"
+	if locked := lock.Lookup(dependency); locked != nil {
+		t.Errorf("Lookup() = %+v, expected nil as the ref changed", locked)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []*Dependency{
+		{Git: "https://example.com/acme.git"},
+		{Name: "acme"},
+		{Name: "acme", Git: "https://example.com/acme.git", URL: "https://example.com/acme.zip"},
+		{Name: "acme", URL: "https://example.com/acme.zip", Ref: "v1"},
+		{Name: "acme", Git: "https://example.com/acme.git", Subdir: "../proto"},
+	}
+	for _, dependency := range invalid {
+		if err := dependency.Validate(); err == nil {
+			t.Errorf("Validate(%+v) expected error", dependency)
+		}
+	}
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	args = append([]string{"-c", "user.name=protob", "-c", "user.email=protob@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %s", args, out)
+	}
+}
+
+func tarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
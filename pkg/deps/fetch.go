@@ -0,0 +1,200 @@
+package deps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"protob/pkg/git"
+	"protob/pkg/os/fs"
+)
+
+// Fetcher fetches dependencies into the cache directory, every version
+// is fetched once and shared by projects
+type Fetcher struct {
+	// cache directory of the dependencies
+	dir string
+
+	// client to download archives
+	client *http.Client
+}
+
+// Fetch fetches the dependency at the locked version, or at the latest
+// version of the ref if locked is nil, returns the version fetched and
+// the include path of the dependency
+func (f *Fetcher) Fetch(ctx context.Context, d *Dependency, locked *Locked) (*Locked, string, error) {
+	if err := d.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	if d.Git != "" {
+		return f.fetchGit(d, locked)
+	}
+	return f.fetchArchive(ctx, d, locked)
+}
+
+// Include returns the include path of the locked version, and whether
+// the version is fetched
+func (f *Fetcher) Include(locked *Locked) (string, bool) {
+	if locked.Commit == "" && locked.SHA256 == "" {
+		return "", false
+	}
+
+	include := fs.Join(f.source(locked), locked.Subdir)
+	if ok, _ := fs.IsDir(include); !ok {
+		return "", false
+	}
+	return include, true
+}
+
+// fetchGit fetches the git repository into a bare repository, and exports
+// the tree of the commit resolved from ref or locked
+func (f *Fetcher) fetchGit(d *Dependency, locked *Locked) (*Locked, string, error) {
+	version := &Locked{Name: d.Name, Git: d.Git, Ref: d.Ref, Subdir: d.subdir()}
+	if locked != nil {
+		version.Commit = locked.Commit
+		if include, ok := f.Include(version); ok {
+			return version, include, nil
+		}
+	}
+
+	repo := fs.Join(f.dir, "repo", hash(d.Git))
+	if err := git.Fetch(repo, d.Git, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+HEAD:"+remoteHead); err != nil {
+		return nil, "", fmt.Errorf("deps: %s: %s", d.Name, err)
+	}
+
+	ref := d.ref()
+	if version.Commit != "" {
+		ref = version.Commit
+	} else if ref == "HEAD" {
+		ref = remoteHead
+	}
+
+	commit, err := git.ResolveCommit(repo, ref)
+	if err != nil || commit == "" {
+		return nil, "", fmt.Errorf("deps: %s: unable to resolve '%s'", d.Name, ref)
+	}
+	version.Commit = commit
+
+	if ok, _ := fs.IsDir(f.source(version)); !ok {
+		err := f.install(f.source(version), func(dir string) error {
+			return git.Export(repo, commit, dir)
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("deps: %s: %s", d.Name, err)
+		}
+	}
+	return f.include(version)
+}
+
+// fetchArchive downloads the archive and extracts it, the checksum of
+// the archive must be the same as locked
+func (f *Fetcher) fetchArchive(ctx context.Context, d *Dependency, locked *Locked) (*Locked, string, error) {
+	version := &Locked{Name: d.Name, URL: d.URL, Subdir: d.subdir()}
+	if locked != nil {
+		version.SHA256 = locked.SHA256
+		if include, ok := f.Include(version); ok {
+			return version, include, nil
+		}
+	}
+
+	content, err := f.download(ctx, d.URL)
+	if err != nil {
+		return nil, "", fmt.Errorf("deps: %s: %s", d.Name, err)
+	}
+
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	if locked != nil && locked.SHA256 != checksum {
+		return nil, "", fmt.Errorf("deps: %s: checksum mismatch, expected %s, got %s", d.Name, locked.SHA256, checksum)
+	}
+	version.SHA256 = checksum
+
+	if ok, _ := fs.IsDir(f.source(version)); !ok {
+		err := f.install(f.source(version), func(dir string) error {
+			return extract(content, dir)
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("deps: %s: %s", d.Name, err)
+		}
+	}
+	return f.include(version)
+}
+
+// download returns the content of url
+func (f *Fetcher) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// install fill a temporary directory then rename it into dst, so the
+// interrupted fetches never leave partial sources in cache
+func (f *Fetcher) install(dst string, fill func(dir string) error) error {
+	if err := os.MkdirAll(filepath.Dir(dst), fs.DirectoryPerm); err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempDir(filepath.Dir(dst), ".fetch")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(temp) }()
+
+	if err := fill(temp); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp, dst); err != nil {
+		if ok, _ := fs.IsDir(dst); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// include returns the version and its include path, the subdir must
+// exist in the source
+func (f *Fetcher) include(version *Locked) (*Locked, string, error) {
+	include, ok := f.Include(version)
+	if !ok {
+		return nil, "", fmt.Errorf("deps: %s: subdir '%s' not found", version.Name, version.Subdir)
+	}
+	return version, include, nil
+}
+
+// source returns the cache directory of the version
+func (f *Fetcher) source(version *Locked) string {
+	if version.Git != "" {
+		return fs.Join(f.dir, "git", version.Commit)
+	}
+	return fs.Join(f.dir, "archive", version.SHA256)
+}
+
+// hash returns the short hash of s for directory name
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewFetcher create a fetcher caches dependencies in dir, the archives
+// are downloaded by client
+func NewFetcher(dir string, client *http.Client) *Fetcher {
+	return &Fetcher{dir: dir, client: client}
+}
@@ -0,0 +1,76 @@
+package deps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"protob/pkg/os/fs"
+)
+
+const (
+	// LockFilename is the name of lock file next to the configuration file
+	LockFilename = "protob.lock"
+)
+
+// Locked represents the exact version of a dependency
+type Locked struct {
+	Name   string `json:"name"`
+	Git    string `json:"git,omitempty"`
+	Ref    string `json:"ref,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Subdir string `json:"subdir,omitempty"`
+
+	// commit the ref of git repository resolved to
+	Commit string `json:"commit,omitempty"`
+
+	// sha256 of the archive in hex
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// matches reports whether the locked version is of the dependency
+func (l *Locked) matches(d *Dependency) bool {
+	return l.Name == d.Name && l.Git == d.Git && l.Ref == d.Ref && l.URL == d.URL && l.Subdir == d.subdir()
+}
+
+// Lock represents the exact versions of all dependencies of project
+type Lock struct {
+	Dependencies []*Locked `json:"dependencies"`
+}
+
+// Lookup returns the locked version of dependency, or nil if not locked
+// or the source of dependency changed
+func (l *Lock) Lookup(d *Dependency) *Locked {
+	for _, locked := range l.Dependencies {
+		if locked.matches(d) {
+			return locked
+		}
+	}
+	return nil
+}
+
+// Save writes the lock into path
+func (l *Lock) Save(path string) error {
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(path, bytes.NewReader(append(content, '\n')), fs.RegularFilePerm)
+}
+
+// LoadLock reads the lock from path, an empty lock is returned if not exists
+func LoadLock(path string) (*Lock, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Lock{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{}
+	if err := json.Unmarshal(content, lock); err != nil {
+		return nil, fmt.Errorf("deps: %s: %s", path, err)
+	}
+	return lock, nil
+}
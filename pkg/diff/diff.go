@@ -0,0 +1,212 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// contextLines is the number of unchanged lines around changes
+	contextLines = 3
+)
+
+// opKind represents the kind of edit operation
+type opKind uint8
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op represents an edit operation on a line
+type op struct {
+	kind opKind
+
+	// index of the line in a and b
+	a, b int
+}
+
+// Unified returns the unified diff from a to b with three lines of context,
+// empty if a and b are the same
+func Unified(fromName, toName, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	linesA, linesB := splitLines(a), splitLines(b)
+	ops := edits(linesA, linesB)
+
+	var out strings.Builder
+	out.WriteString("--- " + fromName + "\n")
+	out.WriteString("+++ " + toName + "\n")
+
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].kind == opEqual {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// extend the hunk until the gap of unchanged lines is wide enough
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != opEqual {
+				end = i + 1
+			} else if i-end >= 2*contextLines {
+				break
+			}
+		}
+
+		first := max(start-contextLines, 0)
+		last := min(end+contextLines, len(ops))
+		writeHunk(&out, ops[first:last], linesA, linesB)
+		start = last
+	}
+	return out.String()
+}
+
+// writeHunk writes the header and lines of a hunk
+func writeHunk(out *strings.Builder, ops []op, a, b []string) {
+	startA, startB, countA, countB := -1, -1, 0, 0
+	for _, o := range ops {
+		if o.kind != opInsert {
+			if startA < 0 {
+				startA = o.a
+			}
+			countA++
+		}
+		if o.kind != opDelete {
+			if startB < 0 {
+				startB = o.b
+			}
+			countB++
+		}
+	}
+
+	_, _ = fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(startA, countA, ops[0].a), hunkRange(startB, countB, ops[0].b))
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			writeLine(out, " ", a[o.a])
+		case opDelete:
+			writeLine(out, "-", a[o.a])
+		case opInsert:
+			writeLine(out, "+", b[o.b])
+		}
+	}
+}
+
+// hunkRange returns the range of lines in form of start,count
+func hunkRange(start, count, fallback int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", fallback)
+	}
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// writeLine writes a line of hunk, marks if the line without newline
+func writeLine(out *strings.Builder, prefix, line string) {
+	out.WriteString(prefix + line)
+	if !strings.HasSuffix(line, "\n") {
+		out.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// splitLines splits text into lines with the newline kept
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// edits returns the shortest edit script from a to b by myers algorithm
+func edits(a, b []string) []op {
+	n, m := len(a), len(b)
+	limit := n + m
+	v := make([]int, 2*limit+2)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[limit+k-1] < v[limit+k+1]) {
+				x = v[limit+k+1]
+			} else {
+				x = v[limit+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[limit+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d, limit)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the trace of myers algorithm back into edit operations
+func backtrack(trace [][]int, a, b []string, d, limit int) []op {
+	x, y := len(a), len(b)
+	var ops []op
+	for ; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[limit+k-1] < v[limit+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[limit+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			ops = append(ops, op{kind: opEqual, a: x, b: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, op{kind: opInsert, a: x, b: y})
+			} else {
+				x--
+				ops = append(ops, op{kind: opDelete, a: x, b: y})
+			}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// max returns the larger one of a and b
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// min returns the smaller one of a and b
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
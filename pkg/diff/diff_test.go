@@ -0,0 +1,43 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n14\n15\n16"
+
+	expected := `--- a.proto
++++ b.proto
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,6 +10,6 @@
+ 10
+ 11
+ 12
+-13
+ 14
+ 15
++16
+\ No newline at end of file
+`
+	if got := Unified("a.proto", "b.proto", a, b); got != expected {
+		t.Errorf("Unified() =\n%s", got)
+	}
+
+	if got := Unified("a", "b", a, a); got != "" {
+		t.Errorf("Unified() of the same = %q", got)
+	}
+
+	if got := Unified("a", "b", "", "x\n"); !strings.Contains(got, "@@ -0,0 +1 @@\n+x\n") {
+		t.Errorf("Unified() from empty =\n%s", got)
+	}
+}
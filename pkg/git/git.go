@@ -0,0 +1,189 @@
+package git
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"strings"
+)
+
+var (
+	// ErrNotFound represents the git executable not found
+	ErrNotFound = errors.New("git: git not found")
+)
+
+// Run runs git with arguments in dir, returns the trimmed stdout
+func Run(dir string, args ...string) (string, error) {
+	out, err := run(dir, args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+// run runs git with arguments in dir, the stderr is returned as error
+func run(dir string, args ...string) ([]byte, error) {
+	executable, err := exec.LookPath("git")
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git: %s", msg)
+		}
+		return nil, fmt.Errorf("git: %s", err)
+	}
+	return out, nil
+}
+
+// Toplevel returns the root of the working tree which contains dir, and
+// the path of dir relative to the root
+func Toplevel(dir string) (string, string, error) {
+	root, err := Run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix, err := Run(dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", "", err
+	}
+	return root, prefix, nil
+}
+
+// ResolveCommit returns the commit hash of the ref in repository at dir
+func ResolveCommit(dir, ref string) (string, error) {
+	return Run(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+}
+
+// Fetch fetches refspecs from the repository at url into the bare
+// repository at dir, which is initialized if not exists
+func Fetch(dir, url string, refspecs ...string) error {
+	if ok, _ := fs.IsDir(dir); !ok {
+		if err := os.MkdirAll(dir, fs.DirectoryPerm); err != nil {
+			return err
+		}
+		if _, err := run(dir, "init", "--bare", "--quiet"); err != nil {
+			return err
+		}
+	}
+
+	_, err := run(dir, append([]string{"fetch", "--quiet", "--force", url}, refspecs...)...)
+	return err
+}
+
+// Export writes files of the tree at ref in repository at dir into dst,
+// only the given paths relative to the root are exported if any, the paths
+// not exist at ref are ignored. The archive is streamed, so the tree is
+// never held in memory
+func Export(dir, ref, dst string, paths ...string) error {
+	var cleaned []string
+	for _, path := range paths {
+		if path = strings.Trim(fs.NormalizePath(filepath.Clean(path)), "/"); path == "." || path == "" {
+			cleaned = nil
+			break
+		}
+		cleaned = append(cleaned, path)
+	}
+
+	args := []string{"archive", "--format=tar", ref}
+	if len(cleaned) != 0 {
+		existing, err := existingPaths(dir, ref, cleaned)
+		if err != nil || len(existing) == 0 {
+			return err
+		}
+		args = append(append(args, "--"), existing...)
+	}
+
+	executable, err := exec.LookPath("git")
+	if err != nil {
+		return ErrNotFound
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	extractErr := extract(out, dst)
+	_, _ = io.Copy(ioutil.Discard, out)
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("git: %s", msg)
+		}
+		return fmt.Errorf("git: %s", err)
+	}
+	return extractErr
+}
+
+// existingPaths returns the paths exist in the tree at ref
+func existingPaths(dir, ref string, paths []string) ([]string, error) {
+	out, err := run(dir, append([]string{"ls-tree", "--name-only", "-z", ref, "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			existing = append(existing, name)
+		}
+	}
+	return existing, nil
+}
+
+// extract writes files of the tar archive read from r into dst
+func extract(r io.Reader, dst string) error {
+	rd := tar.NewReader(r)
+	for {
+		header, err := rd.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+			return fmt.Errorf("git: illegal path '%s' in archive", header.Name)
+		}
+
+		path := filepath.Join(dst, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, fs.DirectoryPerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := fs.WriteFile(path, rd, os.FileMode(header.Mode)&os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(path), fs.DirectoryPerm); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, path); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"protob/pkg/os/fs/fstest"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	repo := fstest.GitRepo(t, map[string]string{
+		"protob.yaml":            "groups: []\n",
+		"api/v1/a.proto":         "a",
+		"api/v2/b.proto":         "b",
+		"third_party/c.proto":    "c",
+		"docs/large/readme.html": "docs",
+	})
+	commit, err := ResolveCommit(repo, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		paths    []string
+		exported []string
+		skipped  []string
+	}{
+		{name: "whole tree",
+			exported: []string{"protob.yaml", "api/v1/a.proto", "docs/large/readme.html"}},
+		{name: "whole tree by dot", paths: []string{"api", "."},
+			exported: []string{"api/v1/a.proto", "docs/large/readme.html"}},
+		{name: "only paths", paths: []string{"protob.yaml", "api/v1/", "third_party", "missing"},
+			exported: []string{"protob.yaml", "api/v1/a.proto", "third_party/c.proto"},
+			skipped:  []string{"api/v2/b.proto", "docs/large/readme.html"}},
+		{name: "only missing", paths: []string{"missing"},
+			skipped: []string{"protob.yaml", "api/v1/a.proto"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dst := fstest.TempDir(t, nil)
+			if err := Export(repo, commit, dst, test.paths...); err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range test.exported {
+				if ok, _ := fs.IsFile(filepath.Join(dst, file)); !ok {
+					t.Errorf("Export(%v) missing %s", test.paths, file)
+				}
+			}
+			for _, file := range test.skipped {
+				if ok, _ := fs.IsFile(filepath.Join(dst, file)); ok {
+					t.Errorf("Export(%v) exported %s, expected skipped", test.paths, file)
+				}
+			}
+		})
+	}
+}
+
+func TestExportUnknownRef(t *testing.T) {
+	repo := fstest.GitRepo(t, map[string]string{"a.proto": "a"})
+	if err := Export(repo, "unknown", fstest.TempDir(t, nil)); err == nil {
+		t.Errorf("Export() of unknown ref succeeded, expected error")
+	}
+}
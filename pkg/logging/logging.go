@@ -12,6 +12,8 @@ var (
 	errTextColor     = color.New(color.FgRed)
 	successHeadColor = color.New(color.BgGreen, color.FgWhite, color.Bold)
 	successTextColor = color.New(color.FgGreen)
+	infoHeadColor    = color.New(color.BgBlue, color.FgWhite, color.Bold)
+	infoTextColor    = color.New(color.FgBlue)
 )
 
 type Logger struct {
@@ -23,14 +25,23 @@ func (log *Logger) Success(format string, args ...interface{}) {
 	_, _ = successTextColor.Fprintf(log.writer, " "+format+"\n", args...)
 }
 
+func (log *Logger) Info(format string, args ...interface{}) {
+	_, _ = infoHeadColor.Fprint(log.writer, "  INFO   ")
+	_, _ = infoTextColor.Fprintf(log.writer, " "+format+"\n", args...)
+}
+
 func (log *Logger) Error(format string, args ...interface{}) {
 	_, _ = errHeadColor.Fprint(log.writer, "  ERROR  ")
 	_, _ = errTextColor.Fprintf(log.writer, " "+format+"\n", args...)
 }
 
 func (log *Logger) Fatal(format string, args ...interface{}) {
+	log.Exit(1, format, args...)
+}
+
+func (log *Logger) Exit(code int, format string, args ...interface{}) {
 	log.Error(format, args...)
-	os.Exit(1)
+	os.Exit(code)
 }
 
 var defaultLogger = NewLogger(os.Stderr)
@@ -43,6 +54,10 @@ func Success(format string, args ...interface{}) {
 	defaultLogger.Success(format, args...)
 }
 
+func Info(format string, args ...interface{}) {
+	defaultLogger.Info(format, args...)
+}
+
 func Error(format string, args ...interface{}) {
 	defaultLogger.Error(format, args...)
 }
@@ -50,3 +65,7 @@ func Error(format string, args ...interface{}) {
 func Fatal(format string, args ...interface{}) {
 	defaultLogger.Fatal(format, args...)
 }
+
+func Exit(code int, format string, args ...interface{}) {
+	defaultLogger.Exit(code, format, args...)
+}
@@ -66,3 +66,15 @@ func WriteFile(filename string, dataSource io.Reader, perm os.FileMode) error {
 
 	return nil
 }
+
+// CopyFile copy content of src into dst, the not exists directories
+// will be auto created
+func CopyFile(src, dst string, perm os.FileMode) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	return WriteFile(dst, file, perm)
+}
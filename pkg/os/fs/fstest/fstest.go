@@ -0,0 +1,89 @@
+// Package fstest implements utilities of file system for tests
+package fstest
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"strings"
+	"testing"
+)
+
+// TempDir creates a temporary directory removed when the test and its
+// subtests complete, files of slash separated paths are written into it
+func TempDir(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "protob-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	WriteFiles(t, dir, files)
+	return dir
+}
+
+// WriteFiles writes files of slash separated paths relative to dir, the
+// parent directories are created and the existing files are overwritten
+func WriteFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+
+	for file, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := fs.WriteFile(path, strings.NewReader(content), fs.RegularFilePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Files returns empty files of the slash separated paths, to create a
+// tree by TempDir when only the paths matter
+func Files(paths ...string) map[string]string {
+	files := make(map[string]string)
+	for _, path := range paths {
+		files[path] = ""
+	}
+	return files
+}
+
+// Chdir changes the working directory to dir, which is restored when the
+// test and its subtests complete
+func Chdir(t testing.TB, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+// GitRepo creates a temporary git repository with the files committed,
+// the test is skipped if git is not installed
+func GitRepo(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir := TempDir(t, files)
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "-A"},
+		{"-c", "user.name=protob", "-c", "user.email=protob@example.com", "commit", "--quiet", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s", args[0], out)
+		}
+	}
+	return dir
+}
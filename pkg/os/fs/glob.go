@@ -0,0 +1,55 @@
+package fs
+
+import (
+	"path"
+	"strings"
+)
+
+// HasMeta reports whether path contains any of the magic characters
+func HasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// Match reports whether name matches the shell pattern, the '**' element
+// matches zero or more directories, both arguments must be normalized
+func Match(pattern, name string) bool {
+	return matchElements(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchElements match each element of the pattern and name
+func matchElements(patterns, names []string) bool {
+	for len(patterns) != 0 {
+		if patterns[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matchElements(patterns[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+		if ok, err := path.Match(patterns[0], names[0]); err != nil || !ok {
+			return false
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+
+	return len(names) == 0
+}
+
+// SplitPattern split pattern into the static directory and the rest glob
+func SplitPattern(pattern string) (string, string) {
+	elements := strings.Split(pattern, "/")
+	for i, element := range elements {
+		if HasMeta(element) {
+			if i == 0 {
+				return ".", pattern
+			}
+			return strings.Join(elements[:i], "/"), strings.Join(elements[i:], "/")
+		}
+	}
+	return pattern, ""
+}
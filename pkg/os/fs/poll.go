@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// fileState represents the state of file when polled
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// Poller represents a portable watcher which compares the state of
+// files between polls
+type Poller struct {
+	// state of files at the last poll
+	states map[string]fileState
+}
+
+// Poll returns paths changed, created or removed since the last poll,
+// paths polled at the first time are not reported as changed
+func (p *Poller) Poll(paths []string) []string {
+	var changed []string
+	states := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		if _, ok := states[path]; ok {
+			continue
+		}
+
+		state := fileState{}
+		if stat, err := os.Stat(path); err == nil {
+			state = fileState{exists: true, size: stat.Size(), modTime: stat.ModTime()}
+		}
+		states[path] = state
+
+		if previous, ok := p.states[path]; ok && previous != state {
+			changed = append(changed, path)
+		}
+	}
+
+	for path, previous := range p.states {
+		if _, ok := states[path]; !ok && previous.exists {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				changed = append(changed, path)
+			}
+		}
+	}
+
+	p.states = states
+	sort.Strings(changed)
+	return changed
+}
+
+// NewPoller create a poller without any state
+func NewPoller() *Poller {
+	return &Poller{states: make(map[string]fileState)}
+}
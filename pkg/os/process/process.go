@@ -0,0 +1,36 @@
+package process
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Run starts the command in a new process group and waits for it, the
+// whole process tree is killed if ctx is done before the command exits,
+// and the error of ctx is returned
+func Run(ctx context.Context, cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	killed := make(chan struct{})
+	go func() {
+		defer close(killed)
+		select {
+		case <-ctx.Done():
+			killProcessTree(cmd)
+		case <-exited:
+		}
+	}()
+
+	err := cmd.Wait()
+	close(exited)
+	<-killed
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
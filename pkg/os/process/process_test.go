@@ -0,0 +1,35 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is required")
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command("sh", "-c", "echo started; sleep 10 & sleep 10")
+	cmd.Stdout = &out
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := Run(ctx, cmd); err != context.DeadlineExceeded {
+		t.Errorf("Run() = %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() returns after %s, the process tree is not killed", elapsed)
+	}
+
+	if err := Run(context.Background(), exec.Command("sh", "-c", "exit 3")); err == nil {
+		t.Errorf("Run() = nil, expected exit error")
+	}
+}
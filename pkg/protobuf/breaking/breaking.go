@@ -0,0 +1,370 @@
+package breaking
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Kind represents what is broken by the change
+type Kind string
+
+const (
+	// KindWire represents changes break the binary encoding or rpc calls
+	KindWire Kind = "wire"
+	// KindSource represents changes break the generated code only
+	KindSource Kind = "source"
+)
+
+// Change represents an incompatible change of the schema
+type Change struct {
+	// name of the file contains the change
+	File string `json:"file"`
+
+	// fully qualified name of the changed element
+	Element string `json:"element"`
+
+	Kind Kind `json:"kind"`
+
+	Message string `json:"message"`
+}
+
+// String returns the change in form of file: kind: message
+func (c *Change) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.File, c.Kind, c.Message)
+}
+
+// Compare returns the incompatible changes from the previous descriptor set
+// to the current one, sorted by file and element
+func Compare(previous, current *descriptorpb.FileDescriptorSet) []*Change {
+	c := &comparator{}
+
+	currentFiles := make(map[string]*descriptorpb.FileDescriptorProto)
+	currentServices := make(map[string]*descriptorpb.ServiceDescriptorProto)
+	for _, file := range current.GetFile() {
+		currentFiles[file.GetName()] = file
+		for _, service := range file.GetService() {
+			currentServices[prefixOf(file.GetPackage())+service.GetName()] = service
+		}
+	}
+
+	for _, prev := range previous.GetFile() {
+		c.file = prev.GetName()
+
+		curr, ok := currentFiles[prev.GetName()]
+		if !ok {
+			// services of the removed file are compared with the ones of
+			// the same name moved into other files, or reported removed
+			scope := prefixOf(prev.GetPackage())
+			var moved []*descriptorpb.ServiceDescriptorProto
+			for _, service := range prev.GetService() {
+				if service, ok := currentServices[scope+service.GetName()]; ok {
+					moved = append(moved, service)
+				}
+			}
+
+			c.report(prev.GetPackage(), KindSource, "file '%s' removed", prev.GetName())
+			c.compareServices(scope, prev.GetService(), moved)
+			continue
+		}
+
+		if prev.GetPackage() != curr.GetPackage() {
+			c.report(prev.GetPackage(), KindWire, "package changed from '%s' to '%s'", prev.GetPackage(), curr.GetPackage())
+			continue
+		}
+
+		scope := prefixOf(prev.GetPackage())
+		c.compareMessages(scope, prev.GetMessageType(), curr.GetMessageType())
+		c.compareEnums(scope, prev.GetEnumType(), curr.GetEnumType())
+		c.compareServices(scope, prev.GetService(), curr.GetService())
+	}
+
+	sort.SliceStable(c.changes, func(i, j int) bool {
+		if c.changes[i].File != c.changes[j].File {
+			return c.changes[i].File < c.changes[j].File
+		}
+		return c.changes[i].Element < c.changes[j].Element
+	})
+	return c.changes
+}
+
+// comparator collects changes of the file being compared
+type comparator struct {
+	file    string
+	changes []*Change
+}
+
+// report adds a change of the element in current file
+func (c *comparator) report(element string, kind Kind, format string, args ...interface{}) {
+	c.changes = append(c.changes, &Change{
+		File:    c.file,
+		Element: element,
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// compareMessages compares messages by name, the nested ones included
+func (c *comparator) compareMessages(scope string, previous, current []*descriptorpb.DescriptorProto) {
+	messages := make(map[string]*descriptorpb.DescriptorProto)
+	for _, message := range current {
+		messages[message.GetName()] = message
+	}
+
+	for _, prev := range previous {
+		name := scope + prev.GetName()
+		curr, ok := messages[prev.GetName()]
+		if !ok {
+			c.report(name, KindSource, "message '%s' removed", name)
+			continue
+		}
+
+		c.compareFields(name, prev, curr)
+		c.compareReserved(name, "message", prev.GetReservedRange(), curr.GetReservedRange(), prev.GetReservedName(), curr.GetReservedName())
+		c.compareMessages(name+".", prev.GetNestedType(), curr.GetNestedType())
+		c.compareEnums(name+".", prev.GetEnumType(), curr.GetEnumType())
+	}
+}
+
+// compareFields compares fields of message by number
+func (c *comparator) compareFields(message string, previous, current *descriptorpb.DescriptorProto) {
+	byNumber := make(map[int32]*descriptorpb.FieldDescriptorProto)
+	byName := make(map[string]*descriptorpb.FieldDescriptorProto)
+	for _, field := range current.GetField() {
+		byNumber[field.GetNumber()] = field
+		byName[field.GetName()] = field
+	}
+
+	for _, prev := range previous.GetField() {
+		name := message + "." + prev.GetName()
+		curr, ok := byNumber[prev.GetNumber()]
+		if !ok {
+			if renumbered, ok := byName[prev.GetName()]; ok {
+				c.report(name, KindWire, "field '%s' renumbered from %d to %d", name, prev.GetNumber(), renumbered.GetNumber())
+			} else if !inMessageRanges(prev.GetNumber(), current.GetReservedRange()) {
+				c.report(name, KindWire, "field '%s' removed without reserving number %d", name, prev.GetNumber())
+			}
+			continue
+		}
+
+		if prev.GetName() != curr.GetName() {
+			c.report(name, KindSource, "field %d renamed from '%s' to '%s'", prev.GetNumber(), prev.GetName(), curr.GetName())
+		}
+		if prev.GetType() != curr.GetType() || prev.GetTypeName() != curr.GetTypeName() {
+			c.report(name, KindWire, "field '%s' type changed from %s to %s", name, typeOf(prev), typeOf(curr))
+		}
+		if prev.GetLabel() != curr.GetLabel() {
+			c.report(name, KindWire, "field '%s' label changed from %s to %s", name, labelOf(prev), labelOf(curr))
+		}
+		if prev.OneofIndex == nil != (curr.OneofIndex == nil) {
+			c.report(name, KindSource, "field '%s' moved into or out of oneof", name)
+		}
+	}
+
+	for _, field := range current.GetField() {
+		name := message + "." + field.GetName()
+		if inMessageRanges(field.GetNumber(), previous.GetReservedRange()) {
+			c.report(name, KindWire, "field '%s' uses number %d reserved previously", name, field.GetNumber())
+		}
+		if contains(previous.GetReservedName(), field.GetName()) {
+			c.report(name, KindWire, "field '%s' uses name reserved previously", name)
+		}
+	}
+}
+
+// compareEnums compares enums by name and their values by number
+func (c *comparator) compareEnums(scope string, previous, current []*descriptorpb.EnumDescriptorProto) {
+	enums := make(map[string]*descriptorpb.EnumDescriptorProto)
+	for _, enum := range current {
+		enums[enum.GetName()] = enum
+	}
+
+	for _, prev := range previous {
+		name := scope + prev.GetName()
+		curr, ok := enums[prev.GetName()]
+		if !ok {
+			c.report(name, KindSource, "enum '%s' removed", name)
+			continue
+		}
+
+		values := make(map[int32]*descriptorpb.EnumValueDescriptorProto)
+		for _, value := range curr.GetValue() {
+			values[value.GetNumber()] = value
+		}
+
+		for _, value := range prev.GetValue() {
+			element := name + "." + value.GetName()
+			if renamed, ok := values[value.GetNumber()]; !ok {
+				if !inEnumRanges(value.GetNumber(), curr.GetReservedRange()) {
+					c.report(element, KindWire, "enum value '%s' removed without reserving number %d", element, value.GetNumber())
+				}
+			} else if renamed.GetName() != value.GetName() && !hasEnumValue(curr, value.GetName()) {
+				c.report(element, KindSource, "enum value %d renamed from '%s' to '%s'", value.GetNumber(), value.GetName(), renamed.GetName())
+			}
+		}
+
+		for _, value := range curr.GetValue() {
+			element := name + "." + value.GetName()
+			if inEnumRanges(value.GetNumber(), prev.GetReservedRange()) {
+				c.report(element, KindWire, "enum value '%s' uses number %d reserved previously", element, value.GetNumber())
+			}
+			if contains(prev.GetReservedName(), value.GetName()) {
+				c.report(element, KindWire, "enum value '%s' uses name reserved previously", element)
+			}
+		}
+
+		c.compareReserved(name, "enum", halfOpen(prev.GetReservedRange()), halfOpen(curr.GetReservedRange()), prev.GetReservedName(), curr.GetReservedName())
+	}
+}
+
+// compareReserved reports the reserved ranges and names removed, the
+// ranges are half-open as declared in message descriptors, a range is
+// kept if both ends are still reserved
+func (c *comparator) compareReserved(element, kind string, previous, current []*descriptorpb.DescriptorProto_ReservedRange, previousNames, currentNames []string) {
+	for _, r := range previous {
+		if !inMessageRanges(r.GetStart(), current) || !inMessageRanges(r.GetEnd()-1, current) {
+			c.report(element, KindWire, "reserved range %s removed from %s '%s'", rangeString(r), kind, element)
+		}
+	}
+
+	for _, name := range previousNames {
+		if !contains(currentNames, name) {
+			c.report(element, KindWire, "reserved name '%s' removed from %s '%s'", name, kind, element)
+		}
+	}
+}
+
+// compareServices compares services by name and their methods by name
+func (c *comparator) compareServices(scope string, previous, current []*descriptorpb.ServiceDescriptorProto) {
+	services := make(map[string]*descriptorpb.ServiceDescriptorProto)
+	for _, service := range current {
+		services[service.GetName()] = service
+	}
+
+	for _, prev := range previous {
+		name := scope + prev.GetName()
+		curr, ok := services[prev.GetName()]
+		if !ok {
+			c.report(name, KindWire, "service '%s' removed", name)
+			continue
+		}
+
+		methods := make(map[string]*descriptorpb.MethodDescriptorProto)
+		for _, method := range curr.GetMethod() {
+			methods[method.GetName()] = method
+		}
+
+		for _, method := range prev.GetMethod() {
+			element := name + "." + method.GetName()
+			changed, ok := methods[method.GetName()]
+			if !ok {
+				c.report(element, KindWire, "rpc '%s' removed", element)
+				continue
+			}
+
+			if method.GetInputType() != changed.GetInputType() {
+				c.report(element, KindWire, "rpc '%s' request type changed from %s to %s", element, trimDot(method.GetInputType()), trimDot(changed.GetInputType()))
+			}
+			if method.GetOutputType() != changed.GetOutputType() {
+				c.report(element, KindWire, "rpc '%s' response type changed from %s to %s", element, trimDot(method.GetOutputType()), trimDot(changed.GetOutputType()))
+			}
+			if method.GetClientStreaming() != changed.GetClientStreaming() {
+				c.report(element, KindWire, "rpc '%s' client streaming changed to %t", element, changed.GetClientStreaming())
+			}
+			if method.GetServerStreaming() != changed.GetServerStreaming() {
+				c.report(element, KindWire, "rpc '%s' server streaming changed to %t", element, changed.GetServerStreaming())
+			}
+		}
+	}
+}
+
+// prefixOf returns the prefix of names in package
+func prefixOf(pkg string) string {
+	if pkg == "" {
+		return ""
+	}
+	return pkg + "."
+}
+
+// typeOf returns the display name of the field type
+func typeOf(field *descriptorpb.FieldDescriptorProto) string {
+	if field.GetTypeName() != "" {
+		return trimDot(field.GetTypeName())
+	}
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
+
+// trimDot returns the fully qualified name without the leading dot
+func trimDot(name string) string {
+	return strings.TrimPrefix(name, ".")
+}
+
+// labelOf returns the display name of the field label
+func labelOf(field *descriptorpb.FieldDescriptorProto) string {
+	return strings.ToLower(strings.TrimPrefix(field.GetLabel().String(), "LABEL_"))
+}
+
+// inMessageRanges reports whether number in the half-open ranges
+func inMessageRanges(number int32, ranges []*descriptorpb.DescriptorProto_ReservedRange) bool {
+	for _, r := range ranges {
+		if number >= r.GetStart() && number < r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+// inEnumRanges reports whether number in the inclusive ranges
+func inEnumRanges(number int32, ranges []*descriptorpb.EnumDescriptorProto_EnumReservedRange) bool {
+	for _, r := range ranges {
+		if number >= r.GetStart() && number <= r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEnumValue reports whether the enum has value named name
+func hasEnumValue(enum *descriptorpb.EnumDescriptorProto, name string) bool {
+	for _, value := range enum.GetValue() {
+		if value.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeString returns the half-open range in form of declaration
+func rangeString(r *descriptorpb.DescriptorProto_ReservedRange) string {
+	if r.GetEnd()-1 == r.GetStart() {
+		return fmt.Sprint(r.GetStart())
+	}
+	return fmt.Sprintf("%d to %d", r.GetStart(), r.GetEnd()-1)
+}
+
+// halfOpen converts the inclusive ranges of enum into half-open ranges
+func halfOpen(ranges []*descriptorpb.EnumDescriptorProto_EnumReservedRange) []*descriptorpb.DescriptorProto_ReservedRange {
+	var result []*descriptorpb.DescriptorProto_ReservedRange
+	for _, r := range ranges {
+		end := r.GetEnd()
+		if end < math.MaxInt32 {
+			end++
+		}
+		result = append(result, &descriptorpb.DescriptorProto_ReservedRange{Start: proto.Int32(r.GetStart()), End: proto.Int32(end)})
+	}
+	return result
+}
+
+// contains reports whether the name in names
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
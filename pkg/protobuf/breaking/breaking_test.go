@@ -0,0 +1,145 @@
+package breaking
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func descriptorSet(t *testing.T, text string) *descriptorpb.FileDescriptorSet {
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := prototext.Unmarshal([]byte(text), set); err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestCompare(t *testing.T) {
+	previous := descriptorSet(t, `
+file {
+  name: "api/user.proto"
+  package: "api"
+  message_type {
+    name: "User"
+    field { name: "id" number: 1 type: TYPE_INT64 label: LABEL_OPTIONAL }
+    field { name: "name" number: 2 type: TYPE_STRING label: LABEL_OPTIONAL }
+    field { name: "email" number: 3 type: TYPE_STRING label: LABEL_OPTIONAL }
+    field { name: "tags" number: 4 type: TYPE_STRING label: LABEL_REPEATED }
+    field { name: "age" number: 5 type: TYPE_INT32 label: LABEL_OPTIONAL }
+    field { name: "phone" number: 6 type: TYPE_STRING label: LABEL_OPTIONAL }
+    reserved_range { start: 10 end: 12 }
+    reserved_name: "password"
+    nested_type { name: "Address" }
+  }
+  enum_type {
+    name: "Status"
+    value { name: "STATUS_UNSPECIFIED" number: 0 }
+    value { name: "STATUS_ACTIVE" number: 1 }
+    value { name: "STATUS_DELETED" number: 2 }
+  }
+  service {
+    name: "UserService"
+    method { name: "GetUser" input_type: ".api.GetUserRequest" output_type: ".api.User" }
+    method { name: "DeleteUser" input_type: ".api.DeleteUserRequest" output_type: ".api.User" }
+    method { name: "Watch" input_type: ".api.WatchRequest" output_type: ".api.User" server_streaming: true }
+  }
+}
+file {
+  name: "api/order.proto"
+  package: "api"
+  service {
+    name: "OrderService"
+    method { name: "CreateOrder" input_type: ".api.Order" output_type: ".api.Order" }
+  }
+}
+file {
+  name: "api/billing.proto"
+  package: "api"
+  service {
+    name: "BillingService"
+    method { name: "Charge" input_type: ".api.Charge" output_type: ".api.Charge" }
+    method { name: "Refund" input_type: ".api.Refund" output_type: ".api.Refund" }
+  }
+}
+file {
+  name: "api/legacy.proto"
+  package: "legacy"
+}
+`)
+
+	current := descriptorSet(t, `
+file {
+  name: "api/user.proto"
+  package: "api"
+  message_type {
+    name: "User"
+    field { name: "id" number: 1 type: TYPE_STRING label: LABEL_OPTIONAL }
+    field { name: "full_name" number: 2 type: TYPE_STRING label: LABEL_OPTIONAL }
+    field { name: "email" number: 7 type: TYPE_STRING label: LABEL_OPTIONAL }
+    field { name: "tags" number: 4 type: TYPE_STRING label: LABEL_OPTIONAL }
+    field { name: "password" number: 11 type: TYPE_STRING label: LABEL_OPTIONAL }
+    reserved_range { start: 6 end: 7 }
+  }
+  enum_type {
+    name: "Status"
+    value { name: "STATUS_UNSPECIFIED" number: 0 }
+    value { name: "STATUS_ENABLED" number: 1 }
+  }
+  service {
+    name: "UserService"
+    method { name: "GetUser" input_type: ".api.GetUserRequest" output_type: ".api.UserReply" }
+    method { name: "Watch" input_type: ".api.WatchRequest" output_type: ".api.User" }
+  }
+}
+file {
+  name: "api/payment.proto"
+  package: "api"
+  service {
+    name: "BillingService"
+    method { name: "Charge" input_type: ".api.Charge" output_type: ".api.Charge" }
+  }
+}
+file {
+  name: "api/legacy.proto"
+  package: "legacy.v2"
+}
+`)
+
+	expected := []string{
+		"api/billing.proto: source: file 'api/billing.proto' removed",
+		"api/billing.proto: wire: rpc 'api.BillingService.Refund' removed",
+		"api/legacy.proto: wire: package changed from 'legacy' to 'legacy.v2'",
+		"api/order.proto: source: file 'api/order.proto' removed",
+		"api/order.proto: wire: service 'api.OrderService' removed",
+		"api/user.proto: source: enum value 1 renamed from 'STATUS_ACTIVE' to 'STATUS_ENABLED'",
+		"api/user.proto: wire: enum value 'api.Status.STATUS_DELETED' removed without reserving number 2",
+		"api/user.proto: wire: reserved range 10 to 11 removed from message 'api.User'",
+		"api/user.proto: wire: reserved name 'password' removed from message 'api.User'",
+		"api/user.proto: source: message 'api.User.Address' removed",
+		"api/user.proto: wire: field 'api.User.age' removed without reserving number 5",
+		"api/user.proto: wire: field 'api.User.email' renumbered from 3 to 7",
+		"api/user.proto: wire: field 'api.User.id' type changed from int64 to string",
+		"api/user.proto: source: field 2 renamed from 'name' to 'full_name'",
+		"api/user.proto: wire: field 'api.User.password' uses number 11 reserved previously",
+		"api/user.proto: wire: field 'api.User.password' uses name reserved previously",
+		"api/user.proto: wire: field 'api.User.tags' label changed from repeated to optional",
+		"api/user.proto: wire: rpc 'api.UserService.DeleteUser' removed",
+		"api/user.proto: wire: rpc 'api.UserService.GetUser' response type changed from api.User to api.UserReply",
+		"api/user.proto: wire: rpc 'api.UserService.Watch' server streaming changed to false",
+	}
+
+	changes := Compare(previous, current)
+	for i, change := range changes {
+		if i >= len(expected) || change.String() != expected[i] {
+			t.Errorf("change #%d = %q", i, change)
+		}
+	}
+	if len(changes) != len(expected) {
+		t.Errorf("got %d changes, expected %d", len(changes), len(expected))
+	}
+
+	if changes := Compare(previous, previous); len(changes) != 0 {
+		t.Errorf("Compare() with itself = %v", changes)
+	}
+}
@@ -1,12 +1,15 @@
 package protobuf
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"protob/pkg/os/fs"
+	"protob/pkg/os/process"
 	"strings"
 )
 
@@ -17,29 +20,104 @@ var (
 	ErrCompilerInvalid = errors.New("protoc: invalid executable")
 )
 
-// Compiler represents a protobuf compiler
-type Compiler struct {
+// Compiler represents a protobuf compiler backend
+type Compiler interface {
+	// Compile compile protobuf files in one invocation, stops when ctx is done
+	Compile(ctx context.Context, targets []string, runtime *CompilerRuntime) error
+
+	// Version returns the version of the compiler
+	Version() string
+
+	// Capabilities returns the features supported by the compiler
+	Capabilities() Capabilities
+}
+
+// Capabilities represents a set of optional features of compiler
+type Capabilities uint8
+
+const (
+	// CapArguments represents the external arguments are passed to protoc
+	CapArguments Capabilities = 1 << iota
+	// CapInsertionPoints represents plugins can insert into files generated
+	CapInsertionPoints
+	// CapBuiltinGenerators represents the languages built in protoc are supported
+	CapBuiltinGenerators
+)
+
+// capabilityNames is the names of capabilities in order
+var capabilityNames = []struct {
+	capability Capabilities
+	name       string
+}{
+	{CapArguments, "arguments"},
+	{CapInsertionPoints, "insertion_points"},
+	{CapBuiltinGenerators, "builtin_generators"},
+}
+
+// Has reports whether all of the capabilities are supported
+func (c Capabilities) Has(capabilities Capabilities) bool {
+	return c&capabilities == capabilities
+}
+
+// String returns names of the capabilities separated by comma
+func (c Capabilities) String() string {
+	var names []string
+	for _, capability := range capabilityNames {
+		if c.Has(capability.capability) {
+			names = append(names, capability.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// ProtocCompiler represents the backend runs protoc executable
+type ProtocCompiler struct {
 	// Compiler version number
-	Version string
+	version string
 
 	// path of the compiler
 	path string
 }
 
-// Compile compile protobuf into go file
-func (c *Compiler) Compile(target string, runtime *CompilerRuntime) error {
-	if out, err := exec.Command(c.path, runtime.Build(target)...).CombinedOutput(); err != nil {
-		return errors.New(fmt.Sprintf("%s", out))
+// Compile compile protobuf files into go files in one invocation, protoc
+// and the plugins are killed if ctx is done
+func (c *ProtocCompiler) Compile(ctx context.Context, targets []string, runtime *CompilerRuntime) error {
+	var out bytes.Buffer
+	cmd := exec.Command(c.path, runtime.Build(targets)...)
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := process.Run(ctx, cmd); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return NewCompileError(out.String())
+		}
+		return err
 	}
 	return nil
 }
 
+// Version returns the version printed by protoc --version
+func (c *ProtocCompiler) Version() string {
+	return c.version
+}
+
+// Capabilities returns all of the capabilities
+func (c *ProtocCompiler) Capabilities() Capabilities {
+	return CapArguments | CapInsertionPoints | CapBuiltinGenerators
+}
+
+// Path returns path of the compiler executable
+func (c *ProtocCompiler) Path() string {
+	return c.path
+}
+
 // NewCompiler create a compiler from path
-func NewCompiler(path string) (*Compiler, error) {
+func NewCompiler(path string) (*ProtocCompiler, error) {
 	if path != "" {
 		if ok, _ := fs.IsFile(path); ok {
 			if output, err := exec.Command(path, "--version").Output(); err == nil {
-				return &Compiler{path: path, Version: strings.TrimSpace(string(output))}, nil
+				return &ProtocCompiler{path: path, version: strings.TrimSpace(string(output))}, nil
 			}
 		}
 	}
@@ -48,7 +126,7 @@ func NewCompiler(path string) (*Compiler, error) {
 }
 
 // NewSystemCompiler create a compiler lookup form system path
-func NewSystemCompiler() (*Compiler, error) {
+func NewSystemCompiler() (*ProtocCompiler, error) {
 	if path, err := exec.LookPath(CompilerExecutable); err != nil {
 		return nil, ErrCompilerNotFound
 	} else {
@@ -56,16 +134,47 @@ func NewSystemCompiler() (*Compiler, error) {
 	}
 }
 
+const (
+	// OriginProtoPath represents the include path given by user
+	OriginProtoPath = "proto_path"
+	// OriginManaged represents the include path installed by protob
+	OriginManaged = "protob include"
+	// OriginRemote represents the include path of remote dependency fetched by protob
+	OriginRemote = "remote dependency"
+	// OriginGopath represents the include path of $GOPATH/src
+	OriginGopath = "$GOPATH/src"
+	// OriginGoModules represents the include path of go modules
+	OriginGoModules = "go modules"
+	// OriginTarget represents the include path of directory of targets
+	OriginTarget = "target directory"
+)
+
+// Include represents an include path and where it comes from
+type Include struct {
+	// path of the include directory
+	Path string
+
+	// origin of the include path
+	Origin string
+}
+
 const (
 	extFast uint8 = 1 << iota
 	extFaster
 	extSlick
+	extGolang
 )
 
 // CompilerRuntime represents an runtime for one compile
 type CompilerRuntime struct {
 	// protobuf dependencies
-	dependencies []string
+	dependencies []Include
+
+	// include paths installed by protob, such as well-known types
+	managed []Include
+
+	// include paths of the system, such as $GOPATH/src
+	system []Include
 
 	// whether compile with grpc
 	grpc bool
@@ -73,62 +182,192 @@ type CompilerRuntime struct {
 	// any extension to enabled
 	extension uint8
 
+	// whether gogoslick is disabled even if no other plugins
+	noDefault bool
+
 	// external argument for protobuf compiler
 	arguments []string
 
 	// compiler options: source_relative
 	sourceRelative bool
 
+	// go module path removed from the output path of golang plugins
+	module string
+
 	// output directory
 	output string
+
+	// plugins to generate code
+	plugins []*Plugin
+
+	// code generators built in protoc
+	languages []*Plugin
+
+	// directories to lookup plugin executables before system path
+	pluginDirs []string
+
+	// output directories replaced by staging directories
+	staging map[string]string
+
+	// path of the file descriptor set to write
+	descriptorSet string
+
+	// whether include imports and source info in descriptor set
+	includeImports, includeSourceInfo bool
 }
 
-// Build build compile command arguments
-func (runtime *CompilerRuntime) Build(target string) []string {
+// Build build compile command arguments, all targets are compiled in one
+// invocation, so they should be in the same directory and package
+func (runtime *CompilerRuntime) Build(targets []string) []string {
 	var args []string
-	for _, dependency := range runtime.dependencies {
-		args = append(args, "-I", dependency)
+	for _, include := range runtime.Includes(targets) {
+		args = append(args, "-I", include)
 	}
-	args = append(args, "-I", fs.NormalizePath(filepath.Dir(target)))
 
-	var output string
-	switch runtime.extension {
-	case extFast:
-		output += "gogofast_out="
-	case extFaster:
-		output += "gogofaster_out="
-	case extSlick:
-		output += "gogoslick_out="
+	for _, plugin := range runtime.Plugins() {
+		if path, ok := localPlugin(plugin, runtime.pluginDirs); ok {
+			args = append(args, fmt.Sprintf("--plugin=%s%s=%s", PluginPrefix, plugin.Name, path))
+		}
+
+		output := runtime.stagingDir(runtime.PluginOutputDir(plugin, targets))
+		if len(plugin.Parameters) != 0 {
+			args = append(args, fmt.Sprintf("--%s_out=%s:%s", plugin.Name, strings.Join(plugin.Parameters, ","), output))
+		} else {
+			args = append(args, fmt.Sprintf("--%s_out=%s", plugin.Name, output))
+		}
 	}
 
-	if runtime.grpc {
-		output += "plugins=grpc,"
+	if runtime.descriptorSet != "" {
+		args = append(args, "--descriptor_set_out="+runtime.descriptorSet)
+		if runtime.includeImports {
+			args = append(args, "--include_imports")
+		}
+		if runtime.includeSourceInfo {
+			args = append(args, "--include_source_info")
+		}
 	}
 
-	if runtime.sourceRelative {
-		output += "paths=source_relative,"
+	args = append(args, runtime.arguments...)
+	args = append(args, targets...)
+
+	return args
+}
+
+// Includes returns the include paths in the order of lookup, directories
+// of the targets are placed after the dependencies
+func (runtime *CompilerRuntime) Includes(targets []string) []string {
+	var includes []string
+	for _, include := range runtime.IncludePaths(targets) {
+		includes = append(includes, include.Path)
 	}
+	return includes
+}
 
-	outputDir := runtime.output
-	if outputDir == "" {
-		outputDir = fs.NormalizePath(filepath.Dir(target))
+// IncludePaths returns the include paths with their origins in the order
+// of lookup: the dependencies given by user, the include paths installed
+// by protob, the system include paths and the directories of targets
+func (runtime *CompilerRuntime) IncludePaths(targets []string) []Include {
+	var includes []Include
+	seen := make(map[string]bool)
+	add := func(include Include) {
+		if !seen[include.Path] {
+			seen[include.Path] = true
+			includes = append(includes, include)
+		}
 	}
 
-	args = append(args, fmt.Sprintf("--%s:%s", strings.TrimRight(output, ","), outputDir))
-	args = append(args, target)
+	for _, group := range [][]Include{runtime.dependencies, runtime.managed, runtime.system} {
+		for _, include := range group {
+			add(include)
+		}
+	}
+	for _, target := range targets {
+		add(Include{Path: fs.NormalizePath(filepath.Dir(target)), Origin: OriginTarget})
+	}
+	return includes
+}
 
-	return args
+// OutputDir returns the output directory of targets
+func (runtime *CompilerRuntime) OutputDir(targets []string) string {
+	if runtime.output == "" && len(targets) != 0 {
+		return fs.NormalizePath(filepath.Dir(targets[0]))
+	}
+	return runtime.output
+}
+
+// PluginOutputDir returns the output directory of the plugin
+func (runtime *CompilerRuntime) PluginOutputDir(plugin *Plugin, targets []string) string {
+	if plugin.Out != "" {
+		return plugin.Out
+	}
+	return runtime.OutputDir(targets)
+}
+
+// stagingDir returns the staging directory of output directory, or the
+// output directory itself if not staged
+func (runtime *CompilerRuntime) stagingDir(output string) string {
+	if staging, ok := runtime.staging[output]; ok {
+		return staging
+	}
+	return output
+}
+
+// OutputDirs returns the distinct output directories of all plugins
+func (runtime *CompilerRuntime) OutputDirs(targets []string) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, plugin := range runtime.Plugins() {
+		if dir := runtime.PluginOutputDir(plugin, targets); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Plugins returns the plugins used by runtime followed by the built-in
+// generators, the extension is a preset of plugins, the gogoslick is
+// enabled by default when no other plugins
+func (runtime *CompilerRuntime) Plugins() []*Plugin {
+	extension := runtime.extension
+	if extension == 0 && !runtime.noDefault && len(runtime.plugins) == 0 && runtime.descriptorSet == "" {
+		extension = extSlick
+	}
+
+	presets := presetPlugins(extension, runtime.grpc, runtime.sourceRelative, runtime.module)
+	return append(append(presets, runtime.plugins...), runtime.languages...)
+}
+
+// PluginPath returns path of the plugin executable
+func (runtime *CompilerRuntime) PluginPath(plugin *Plugin) (string, error) {
+	return lookupPlugin(plugin, runtime.pluginDirs)
+}
+
+// Clone returns a copy of runtime with options applied
+func (runtime *CompilerRuntime) Clone(options ...CompileOption) *CompilerRuntime {
+	clone := *runtime
+	clone.dependencies = append([]Include{}, runtime.dependencies...)
+	clone.managed = append([]Include{}, runtime.managed...)
+	clone.system = append([]Include{}, runtime.system...)
+	clone.arguments = append([]string{}, runtime.arguments...)
+	clone.plugins = append([]*Plugin{}, runtime.plugins...)
+	clone.languages = append([]*Plugin{}, runtime.languages...)
+	clone.pluginDirs = append([]string{}, runtime.pluginDirs...)
+	for _, option := range options {
+		option(&clone)
+	}
+	return &clone
 }
 
 // NewCompileRuntime create an runtime for compile by options
 func NewCompileRuntime(options ...CompileOption) *CompilerRuntime {
-	runtime := &CompilerRuntime{dependencies: []string{}, extension: extSlick, arguments: []string{}}
+	runtime := &CompilerRuntime{dependencies: []Include{}, arguments: []string{}}
 	for _, option := range options {
 		option(runtime)
 	}
 
-	if path := os.Getenv("GOPATH"); path != "" {
-		runtime.dependencies = append(runtime.dependencies, fs.Join(path, "src"))
+	if path := os.Getenv("GOPATH"); path != "" && len(runtime.system) == 0 {
+		runtime.system = append(runtime.system, Include{Path: fs.Join(path, "src"), Origin: OriginGopath})
 	}
 
 	return runtime
@@ -166,15 +405,76 @@ func WithExtFaster(faster bool) CompileOption {
 func WithExtSlick(slick bool) CompileOption {
 	return func(runtime *CompilerRuntime) {
 		if slick {
-			runtime.extension = extSlick
+			runtime.extension, runtime.noDefault = extSlick, false
+		}
+	}
+}
+
+// WithoutExtSlick disables gogoslick, including the default one when no
+// other plugins
+func WithoutExtSlick() CompileOption {
+	return func(runtime *CompilerRuntime) {
+		if runtime.extension == extSlick {
+			runtime.extension = 0
 		}
+		runtime.noDefault = true
+	}
+}
+
+// WithGolang sets compile extension using protoc-gen-go and protoc-gen-go-grpc
+func WithGolang(golang bool) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		if golang {
+			runtime.extension = extGolang
+		}
+	}
+}
+
+// WithGoModule sets the go module path removed from output path of golang
+func WithGoModule(module string) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		runtime.module = module
 	}
 }
 
 // WithDependencies add dependencies into runtime
 func WithDependencies(dependencies ...string) CompileOption {
 	return func(runtime *CompilerRuntime) {
-		runtime.dependencies = append(runtime.dependencies, dependencies...)
+		for _, dependency := range dependencies {
+			runtime.dependencies = append(runtime.dependencies, Include{Path: dependency, Origin: OriginProtoPath})
+		}
+	}
+}
+
+// WithRemoteDependencies add include paths of remote dependencies, which
+// are looked up after the dependencies given by user
+func WithRemoteDependencies(dependencies ...string) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		for _, dependency := range dependencies {
+			runtime.dependencies = append(runtime.dependencies, Include{Path: dependency, Origin: OriginRemote})
+		}
+	}
+}
+
+// WithManagedIncludes add include paths installed by protob, the paths
+// not exists are ignored
+func WithManagedIncludes(includes ...string) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		for _, include := range includes {
+			if ok, _ := fs.IsDir(include); ok {
+				runtime.managed = append(runtime.managed, Include{Path: include, Origin: OriginManaged})
+			}
+		}
+	}
+}
+
+// WithGoModules sets the include path of go modules instead of $GOPATH/src,
+// which every module is placed at its module path
+func WithGoModules(root string) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		if root != "" {
+			runtime.system = append(runtime.system, Include{Path: root, Origin: OriginGoModules})
+		}
 	}
 }
 
@@ -198,3 +498,52 @@ func WithOutput(output string) CompileOption {
 		runtime.output = output
 	}
 }
+
+// WithPlugins add plugins into runtime, the gogo extension is disabled
+// unless enabled explicitly
+func WithPlugins(plugins ...*Plugin) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		runtime.plugins = append(runtime.plugins, plugins...)
+	}
+}
+
+// WithLanguages add code generators built in protoc into runtime, which
+// generate alongside the plugins and the extension
+func WithLanguages(languages ...*Plugin) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		for _, language := range languages {
+			language.Builtin = true
+		}
+		runtime.languages = append(runtime.languages, languages...)
+	}
+}
+
+// WithPluginDirs add directories to lookup plugin executables
+func WithPluginDirs(dirs ...string) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		runtime.pluginDirs = append(runtime.pluginDirs, dirs...)
+	}
+}
+
+// WithStaging replace output directories by staging directories
+func WithStaging(staging map[string]string) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		runtime.staging = staging
+	}
+}
+
+// WithoutPlugins disable all plugins and the extension
+func WithoutPlugins() CompileOption {
+	return func(runtime *CompilerRuntime) {
+		runtime.plugins, runtime.languages, runtime.extension = nil, nil, 0
+	}
+}
+
+// WithDescriptorSet writes a file descriptor set of targets into path
+func WithDescriptorSet(path string, includeImports, includeSourceInfo bool) CompileOption {
+	return func(runtime *CompilerRuntime) {
+		runtime.descriptorSet = path
+		runtime.includeImports = includeImports
+		runtime.includeSourceInfo = includeSourceInfo
+	}
+}
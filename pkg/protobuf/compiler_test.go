@@ -1,13 +1,35 @@
 package protobuf
 
 import (
+	"protob/pkg/os/fs"
+	"protob/pkg/os/fs/fstest"
+	"reflect"
 	"testing"
 )
 
 func TestNewSystemCompiler(t *testing.T) {
-	if compiler, err := NewSystemCompiler(); err != nil {
+	if compiler, err := NewSystemCompiler(); err == ErrCompilerNotFound {
+		t.Skip("protoc not found in system path")
+	} else if err != nil {
 		t.Fatal(err)
 	} else {
-		t.Logf("System compiler version: %s", compiler.Version)
+		t.Logf("System compiler version: %s", compiler.Version())
+	}
+}
+
+func TestCompilerRuntimeIncludes(t *testing.T) {
+	managed := fstest.TempDir(t, nil)
+
+	runtime := &CompilerRuntime{system: []Include{{Path: "gopath/src", Origin: OriginGopath}}}
+	for _, option := range []CompileOption{
+		WithManagedIncludes(managed, fs.Join(managed, "not-exists")),
+		WithDependencies("third_party", "api"),
+	} {
+		option(runtime)
+	}
+
+	expected := []string{"third_party", "api", managed, "gopath/src", "api/v1"}
+	if got := runtime.Includes([]string{"api/v1/echo.proto", "api/user.proto"}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Includes() = %v, expected %v", got, expected)
 	}
 }
@@ -5,4 +5,7 @@ package protobuf
 const (
 	// Name of the compiler by default
 	CompilerExecutable = "protoc"
+
+	// Suffix of the executable files
+	ExecutableSuffix = ""
 )
@@ -5,4 +5,7 @@ package protobuf
 const (
 	// Name of the compiler by default
 	CompilerExecutable = "protoc.exe"
+
+	// Suffix of the executable files
+	ExecutableSuffix = ".exe"
 )
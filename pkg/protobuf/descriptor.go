@@ -0,0 +1,42 @@
+package protobuf
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ReadDescriptorSet reads a file descriptor set from path
+func ReadDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(content, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// MergeDescriptorSets merges file descriptor sets into one, the files with
+// the same name are kept only the first one, and error is returned if their
+// contents differ, e.g. two echo.proto in different directories
+func MergeDescriptorSets(sets ...*descriptorpb.FileDescriptorSet) (*descriptorpb.FileDescriptorSet, error) {
+	merged := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]*descriptorpb.FileDescriptorProto)
+	for _, set := range sets {
+		for _, file := range set.GetFile() {
+			if first, ok := seen[file.GetName()]; !ok {
+				seen[file.GetName()] = file
+				merged.File = append(merged.File, file)
+			} else if !proto.Equal(first, file) {
+				return nil, fmt.Errorf("descriptor: different files named %s, add a common include path to make the names unique", file.GetName())
+			}
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,29 @@
+package protobuf
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestMergeDescriptorSets(t *testing.T) {
+	file := func(name, pkg string) *descriptorpb.FileDescriptorProto {
+		return &descriptorpb.FileDescriptorProto{Name: proto.String(name), Package: proto.String(pkg)}
+	}
+
+	v1 := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file("common.proto", "common"), file("echo.proto", "api.v1")}}
+	user := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file("common.proto", "common"), file("user.proto", "api.v1")}}
+	merged, err := MergeDescriptorSets(v1, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.File) != 3 {
+		t.Errorf("MergeDescriptorSets() = %d files, expected 3", len(merged.File))
+	}
+
+	v2 := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file("echo.proto", "api.v2")}}
+	if _, err := MergeDescriptorSets(v1, v2); err == nil {
+		t.Error("MergeDescriptorSets() expected error of different files with the same name")
+	}
+}
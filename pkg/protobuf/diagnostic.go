@@ -0,0 +1,108 @@
+package protobuf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severity represents the severity of a diagnostic
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic represents a message reported by the compiler
+type Diagnostic struct {
+	// file which the message reported on, empty if unknown
+	File string `json:"file,omitempty"`
+
+	// line number starts from 1, 0 if unknown
+	Line int `json:"line,omitempty"`
+
+	// column number starts from 1, 0 if unknown
+	Column int `json:"column,omitempty"`
+
+	// severity of the message
+	Severity Severity `json:"severity"`
+
+	// the message
+	Message string `json:"message"`
+}
+
+// String returns the diagnostic in GCC style: file:line:column: severity: message
+func (d *Diagnostic) String() string {
+	var location string
+	if d.File != "" {
+		location = d.File + ":"
+		if d.Line > 0 {
+			location += strconv.Itoa(d.Line) + ":"
+			if d.Column > 0 {
+				location += strconv.Itoa(d.Column) + ":"
+			}
+		}
+		location += " "
+	}
+	return fmt.Sprintf("%s%s: %s", location, d.Severity, d.Message)
+}
+
+// CompileError represents the compiler exited with failure
+type CompileError struct {
+	// diagnostics parsed from output of the compiler
+	Diagnostics []*Diagnostic
+
+	// output of the compiler
+	Output string
+}
+
+// Error returns output of the compiler
+func (e *CompileError) Error() string {
+	return e.Output
+}
+
+// NewCompileError create a compile error from output of the compiler
+func NewCompileError(output string) *CompileError {
+	output = strings.TrimSpace(output)
+	return &CompileError{Output: output, Diagnostics: ParseDiagnostics(output)}
+}
+
+var (
+	// file.proto:12:5: message or file.proto:12:5: warning: message
+	positionPattern = regexp.MustCompile(`^(.+?):(\d+):(\d+):\s*(.*)$`)
+	// file.proto: message
+	filePattern = regexp.MustCompile(`^(\S+\.proto):\s*(.*)$`)
+)
+
+// ParseDiagnostics parses the output of protoc into diagnostics, lines
+// without location, such as plugin failures, have no file
+func ParseDiagnostics(output string) []*Diagnostic {
+	var diagnostics []*Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		diagnostic := &Diagnostic{Message: strings.TrimSpace(line)}
+		if match := positionPattern.FindStringSubmatch(line); match != nil {
+			diagnostic.File, diagnostic.Message = match[1], match[4]
+			diagnostic.Line, _ = strconv.Atoi(match[2])
+			diagnostic.Column, _ = strconv.Atoi(match[3])
+		} else if match := filePattern.FindStringSubmatch(line); match != nil {
+			diagnostic.File, diagnostic.Message = match[1], match[2]
+		}
+
+		diagnostic.Severity = SeverityError
+		for _, severity := range []Severity{SeverityWarning, SeverityError} {
+			if prefix := string(severity) + ":"; strings.HasPrefix(strings.ToLower(diagnostic.Message), prefix) {
+				diagnostic.Severity = severity
+				diagnostic.Message = strings.TrimSpace(diagnostic.Message[len(prefix):])
+			}
+		}
+
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
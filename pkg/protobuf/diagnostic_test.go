@@ -0,0 +1,33 @@
+package protobuf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	output := `api/echo.proto:3:1: Expected ";".
+api/echo.proto:5:1: warning: Import google/protobuf/empty.proto is unused.
+api/user.proto: Import "api/missing.proto" was not found or had errors.
+--gogoslick_out: protoc-gen-gogoslick: Plugin failed with status code 1.
+`
+
+	expected := []*Diagnostic{
+		{File: "api/echo.proto", Line: 3, Column: 1, Severity: SeverityError, Message: `Expected ";".`},
+		{File: "api/echo.proto", Line: 5, Column: 1, Severity: SeverityWarning, Message: "Import google/protobuf/empty.proto is unused."},
+		{File: "api/user.proto", Severity: SeverityError, Message: `Import "api/missing.proto" was not found or had errors.`},
+		{Severity: SeverityError, Message: "--gogoslick_out: protoc-gen-gogoslick: Plugin failed with status code 1."},
+	}
+
+	diagnostics := ParseDiagnostics(output)
+	if !reflect.DeepEqual(diagnostics, expected) {
+		for _, diagnostic := range diagnostics {
+			t.Logf("got: %#v", diagnostic)
+		}
+		t.Fatal("unexpected diagnostics")
+	}
+
+	if got := diagnostics[0].String(); got != `api/echo.proto:3:1: error: Expected ";".` {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+package protobuf
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"strings"
+	"sync"
+)
+
+// Invocation represents a compile recorded by the fake compiler
+type Invocation struct {
+	// targets compiled in the invocation
+	Targets []string
+
+	// runtime of the invocation
+	Runtime *CompilerRuntime
+
+	// arguments would be passed to protoc
+	Args []string
+}
+
+// FakeCompiler is an in-memory compiler records every invocation without
+// running anything, for testing builds without protoc installed
+type FakeCompiler struct {
+	// CompileFunc is called by Compile if not nil, to write outputs or
+	// return errors, e.g. NewCompileError
+	CompileFunc func(ctx context.Context, targets []string, runtime *CompilerRuntime) error
+
+	version      string
+	capabilities Capabilities
+
+	mu          sync.Mutex
+	invocations []*Invocation
+}
+
+// Compile records the invocation and calls CompileFunc if any
+func (c *FakeCompiler) Compile(ctx context.Context, targets []string, runtime *CompilerRuntime) error {
+	c.mu.Lock()
+	c.invocations = append(c.invocations, &Invocation{
+		Targets: append([]string{}, targets...),
+		Runtime: runtime,
+		Args:    runtime.Build(targets),
+	})
+	c.mu.Unlock()
+
+	if c.CompileFunc != nil {
+		return c.CompileFunc(ctx, targets, runtime)
+	}
+	return nil
+}
+
+// Version returns the version given on creation
+func (c *FakeCompiler) Version() string {
+	return c.version
+}
+
+// Capabilities returns the capabilities given on creation
+func (c *FakeCompiler) Capabilities() Capabilities {
+	return c.capabilities
+}
+
+// Invocations returns the invocations recorded in order of compile
+func (c *FakeCompiler) Invocations() []*Invocation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Invocation{}, c.invocations...)
+}
+
+// Reset clears the recorded invocations
+func (c *FakeCompiler) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invocations = nil
+}
+
+// NewFakeCompiler create a fake compiler reports the version and capabilities
+func NewFakeCompiler(version string, capabilities Capabilities) *FakeCompiler {
+	return &FakeCompiler{version: version, capabilities: capabilities}
+}
+
+// FakeGenerate is a CompileFunc writes a .pb.go named after every target
+// into the output directory of every plugin, the content is the name of
+// the plugin
+func FakeGenerate(ctx context.Context, targets []string, runtime *CompilerRuntime) error {
+	for _, plugin := range runtime.Plugins() {
+		out := runtime.stagingDir(runtime.PluginOutputDir(plugin, targets))
+		for _, target := range targets {
+			name := strings.TrimSuffix(filepath.Base(target), ".proto") + ".pb.go"
+			if err := ioutil.WriteFile(filepath.Join(out, name), []byte(plugin.Name), fs.RegularFilePerm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
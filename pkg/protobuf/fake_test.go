@@ -0,0 +1,66 @@
+package protobuf
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+var (
+	_ Compiler = (*ProtocCompiler)(nil)
+	_ Compiler = (*NativeCompiler)(nil)
+	_ Compiler = (*FakeCompiler)(nil)
+)
+
+func TestFakeCompiler(t *testing.T) {
+	compiler := NewFakeCompiler("libprotoc 3.15.0", CapArguments)
+	if compiler.Version() != "libprotoc 3.15.0" || !compiler.Capabilities().Has(CapArguments) {
+		t.Errorf("Version() = %s, Capabilities() = %s", compiler.Version(), compiler.Capabilities())
+	}
+
+	runtime := NewCompileRuntime(WithPlugins(&Plugin{Name: "go"}), WithAddArguments("--fatal_warnings"))
+	if err := compiler.Compile(context.Background(), []string{"api/user.proto"}, runtime); err != nil {
+		t.Fatal(err)
+	}
+
+	compiler.CompileFunc = func(ctx context.Context, targets []string, runtime *CompilerRuntime) error {
+		return NewCompileError(targets[0] + ":1:1: syntax error")
+	}
+	err := compiler.Compile(context.Background(), []string{"api/order.proto"}, runtime)
+	if compileErr, ok := err.(*CompileError); !ok || compileErr.Diagnostics[0].File != "api/order.proto" {
+		t.Errorf("Compile() = %v, expected error of api/order.proto", err)
+	}
+
+	invocations := compiler.Invocations()
+	if len(invocations) != 2 {
+		t.Fatalf("Invocations() = %d, expected 2", len(invocations))
+	}
+	if !reflect.DeepEqual(invocations[0].Targets, []string{"api/user.proto"}) || invocations[0].Runtime != runtime {
+		t.Errorf("Invocations()[0] = %+v", invocations[0])
+	}
+	if expected := runtime.Build([]string{"api/order.proto"}); !reflect.DeepEqual(invocations[1].Args, expected) {
+		t.Errorf("Invocations()[1].Args = %v, expected %v", invocations[1].Args, expected)
+	}
+
+	compiler.Reset()
+	if n := len(compiler.Invocations()); n != 0 {
+		t.Errorf("Invocations() = %d after Reset, expected 0", n)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	cases := map[Capabilities]string{
+		0:                                 "none",
+		CapArguments:                      "arguments",
+		CapArguments | CapInsertionPoints: "arguments, insertion_points",
+	}
+	for capabilities, expected := range cases {
+		if got := capabilities.String(); got != expected {
+			t.Errorf("String() = %s, expected %s", got, expected)
+		}
+	}
+
+	if (CapArguments).Has(CapArguments | CapInsertionPoints) {
+		t.Errorf("Has() reports capabilities not all supported")
+	}
+}
@@ -0,0 +1,403 @@
+package format
+
+import (
+	"protob/pkg/protobuf/parser"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// indentUnit is the indentation of each level
+	indentUnit = "  "
+)
+
+// Source parses and formats the protobuf source
+func Source(name string, content []byte) ([]byte, error) {
+	file, err := parser.Parse(name, content)
+	if err != nil {
+		return nil, err
+	}
+	return Format(file), nil
+}
+
+// Format prints the syntax tree in canonical layout: two spaces indent,
+// imports and file options sorted, options first in every body, values
+// of fields and enum values aligned, and all of the comments preserved
+func Format(file *parser.File) []byte {
+	p := &printer{}
+
+	var header, imports, options, rest []parser.Decl
+	for _, decl := range file.Decls {
+		switch decl.(type) {
+		case *parser.Syntax, *parser.Package:
+			header = append(header, decl)
+		case *parser.Import:
+			imports = append(imports, decl)
+		case *parser.Option:
+			options = append(options, decl)
+		case *parser.Empty:
+		default:
+			rest = append(rest, decl)
+		}
+	}
+
+	sort.SliceStable(header, func(i, j int) bool {
+		_, isSyntax := header[i].(*parser.Syntax)
+		return isSyntax && !isSyntaxDecl(header[j])
+	})
+	sort.SliceStable(imports, func(i, j int) bool {
+		return imports[i].(*parser.Import).Path < imports[j].(*parser.Import).Path
+	})
+	sort.SliceStable(options, func(i, j int) bool {
+		a, b := options[i].(*parser.Option), options[j].(*parser.Option)
+		if custom := strings.HasPrefix(a.Name, "("); custom != strings.HasPrefix(b.Name, "(") {
+			return !custom
+		}
+		return a.Name < b.Name
+	})
+
+	for _, decl := range header {
+		p.blank()
+		p.decl(decl, 0)
+	}
+	for _, section := range [][]parser.Decl{imports, options} {
+		p.blank()
+		for _, decl := range section {
+			p.decl(decl, 0)
+		}
+	}
+	p.blank()
+	p.body(rest, true)
+
+	if len(file.EndComments) != 0 {
+		p.blank()
+		p.comments(file.EndComments, 0)
+	}
+	out := strings.Trim(p.b.String(), "\n")
+	if out == "" {
+		return nil
+	}
+	return []byte(out + "\n")
+}
+
+// printer writes the formatted source
+type printer struct {
+	b strings.Builder
+
+	// level of the indentation
+	depth int
+
+	// end line of the last printed declaration in the source
+	lastLine int
+
+	// whether the last written line is a blank line or an opening brace
+	fresh bool
+}
+
+// line writes a line with indentation
+func (p *printer) line(text string) {
+	p.b.WriteString(strings.Repeat(indentUnit, p.depth) + text + "\n")
+	p.fresh = strings.HasSuffix(text, "{")
+}
+
+// blank writes a blank line unless at the beginning of a block
+func (p *printer) blank() {
+	if !p.fresh && p.b.Len() != 0 {
+		p.b.WriteString("\n")
+	}
+	p.fresh = true
+}
+
+// comments writes the comments, the blank lines between comments and
+// before the line of the declaration are kept
+func (p *printer) comments(comments []*parser.Comment, line int) {
+	for i, comment := range comments {
+		if i > 0 && comment.Pos.Line > comments[i-1].End.Line+1 {
+			p.blank()
+		}
+		p.comment(comment)
+	}
+	if n := len(comments); n != 0 && line != 0 && comments[n-1].End.Line < line-1 {
+		p.blank()
+	}
+}
+
+// comment writes a comment, the continuation lines of block comment
+// are re-indented by the change of its column
+func (p *printer) comment(comment *parser.Comment) {
+	lines := strings.Split(comment.Text, "\n")
+	original := strings.Repeat(" ", comment.Pos.Column-1)
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if i > 0 {
+			line = strings.TrimPrefix(strings.ReplaceAll(line, "\t", indentUnit), original)
+		}
+		p.line(line)
+	}
+	p.fresh = false
+}
+
+// trailing returns the trailing comment with a leading space
+func trailing(element *parser.Element) string {
+	if element.Comments.Trailing == nil {
+		return ""
+	}
+	return " " + strings.TrimRight(element.Comments.Trailing.Text, " \t\r")
+}
+
+// body writes the declarations in a file or block, options first and the
+// blank lines between declarations kept, the blocks are always separated
+// by blank lines if top level
+func (p *printer) body(decls []parser.Decl, top bool) {
+	var options, others []parser.Decl
+	for _, decl := range decls {
+		switch decl.(type) {
+		case *parser.Option:
+			options = append(options, decl)
+		case *parser.Empty:
+		default:
+			others = append(others, decl)
+		}
+	}
+
+	for _, decl := range options {
+		p.decl(decl, 0)
+	}
+	if len(options) != 0 && len(others) != 0 {
+		p.blank()
+	}
+
+	widths := alignWidths(others)
+	for i, decl := range others {
+		if i > 0 {
+			start := decl.Elem().Pos.Line
+			if leading := decl.Elem().Comments.Leading; len(leading) != 0 {
+				start = leading[0].Pos.Line
+			}
+			if start > p.lastLine+1 || (top && (isBlock(decl) || isBlock(others[i-1]))) {
+				p.blank()
+			}
+		}
+		p.decl(decl, widths[i])
+	}
+}
+
+// decl writes the declaration with leading and trailing comments, the
+// width is used to align the value of fields and enum values
+func (p *printer) decl(decl parser.Decl, width int) {
+	element := decl.Elem()
+	p.comments(element.Comments.Leading, element.Pos.Line)
+
+	switch d := decl.(type) {
+	case *parser.Syntax:
+		p.line("syntax = " + strconv.Quote(d.Value) + ";" + trailing(element))
+	case *parser.Package:
+		p.line("package " + d.Name + ";" + trailing(element))
+	case *parser.Import:
+		modifier := ""
+		if d.Modifier != "" {
+			modifier = d.Modifier + " "
+		}
+		p.line("import " + modifier + strconv.Quote(d.Path) + ";" + trailing(element))
+	case *parser.Option:
+		p.line("option " + d.Name + " = " + d.Value.Raw + ";" + trailing(element))
+	case *parser.Field:
+		text := pad(fieldPrefix(d), width) + " = " + strconv.Itoa(d.Number) + compactOptions(d.Options)
+		if d.Group != nil {
+			p.block(text, element, d.Group.Decls, d.Group.EndComments)
+		} else {
+			p.line(text + ";" + trailing(element))
+		}
+	case *parser.EnumValue:
+		p.line(pad(d.Name, width) + " = " + strconv.Itoa(d.Number) + compactOptions(d.Options) + ";" + trailing(element))
+	case *parser.Reserved:
+		var items []string
+		for _, r := range d.Ranges {
+			items = append(items, rangeText(r))
+		}
+		for _, name := range d.Names {
+			items = append(items, strconv.Quote(name))
+		}
+		p.line("reserved " + strings.Join(items, ", ") + ";" + trailing(element))
+	case *parser.Extensions:
+		var items []string
+		for _, r := range d.Ranges {
+			items = append(items, rangeText(r))
+		}
+		p.line("extensions " + strings.Join(items, ", ") + compactOptions(d.Options) + ";" + trailing(element))
+	case *parser.Message:
+		p.block("message "+d.Name, element, d.Decls, d.EndComments)
+	case *parser.Enum:
+		p.block("enum "+d.Name, element, d.Decls, d.EndComments)
+	case *parser.Oneof:
+		p.block("oneof "+d.Name, element, d.Decls, d.EndComments)
+	case *parser.Extend:
+		p.block("extend "+d.Type, element, d.Decls, d.EndComments)
+	case *parser.Service:
+		p.block("service "+d.Name, element, d.Decls, d.EndComments)
+	case *parser.RPC:
+		text := "rpc " + d.Name + "(" + streamText(d.InputStream) + d.InputType + ") returns (" + streamText(d.OutputStream) + d.OutputType + ")"
+		if d.Decls == nil {
+			p.line(text + ";" + trailing(element))
+		} else {
+			p.block(text, element, d.Decls, d.EndComments)
+		}
+	}
+
+	p.lastLine = element.End.Line
+	if element.Comments.Trailing != nil && element.Comments.Trailing.End.Line > p.lastLine {
+		p.lastLine = element.Comments.Trailing.End.Line
+	}
+}
+
+// block writes the declaration with body in braces, the empty body is
+// written in one line
+func (p *printer) block(header string, element *parser.Element, decls []parser.Decl, endComments []*parser.Comment) {
+	if len(decls) == 0 && len(endComments) == 0 && element.Comments.Trailing == nil {
+		p.line(header + " {}")
+		return
+	}
+
+	p.line(header + " {" + trailing(element))
+	p.fresh = true
+	p.depth++
+	p.body(decls, false)
+	if len(endComments) != 0 {
+		if len(decls) != 0 && endComments[0].Pos.Line > p.lastLine+1 {
+			p.blank()
+		}
+		p.comments(endComments, 0)
+	}
+	p.depth--
+	p.line("}")
+}
+
+// alignWidths returns the width of name column of each declaration, the
+// consecutive fields and enum values without blank lines between are aligned
+func alignWidths(decls []parser.Decl) []int {
+	widths := make([]int, len(decls))
+	for start := 0; start < len(decls); {
+		end, width := start, 0
+		for ; end < len(decls); end++ {
+			name, ok := alignedName(decls[end])
+			if !ok || (end > start && separated(decls[end-1], decls[end])) {
+				break
+			}
+			if len(name) > width {
+				width = len(name)
+			}
+		}
+
+		if end == start {
+			start++
+			continue
+		}
+		for i := start; i < end; i++ {
+			widths[i] = width
+		}
+		start = end
+	}
+	return widths
+}
+
+// alignedName returns the text before the equal sign of declaration
+// which should be aligned
+func alignedName(decl parser.Decl) (string, bool) {
+	switch d := decl.(type) {
+	case *parser.Field:
+		if d.Group == nil {
+			return fieldPrefix(d), true
+		}
+	case *parser.EnumValue:
+		return d.Name, true
+	}
+	return "", false
+}
+
+// separated reports whether blank lines between the declarations
+func separated(prev, next parser.Decl) bool {
+	end := prev.Elem().End.Line
+	if trailing := prev.Elem().Comments.Trailing; trailing != nil {
+		end = trailing.End.Line
+	}
+
+	start := next.Elem().Pos.Line
+	if leading := next.Elem().Comments.Leading; len(leading) != 0 {
+		start = leading[0].Pos.Line
+	}
+	return start > end+1
+}
+
+// fieldPrefix returns label, type and name of the field
+func fieldPrefix(field *parser.Field) string {
+	var parts []string
+	if field.Label != "" {
+		parts = append(parts, field.Label)
+	}
+
+	switch {
+	case field.IsMap():
+		parts = append(parts, "map<"+field.KeyType+", "+field.ValueType+">")
+	default:
+		parts = append(parts, field.Type)
+	}
+	return strings.Join(append(parts, field.Name), " ")
+}
+
+// compactOptions returns the options in brackets with a leading space
+func compactOptions(options []*parser.Option) string {
+	if len(options) == 0 {
+		return ""
+	}
+
+	var items []string
+	for _, option := range options {
+		items = append(items, option.Name+" = "+option.Value.Raw)
+	}
+	return " [" + strings.Join(items, ", ") + "]"
+}
+
+// rangeText returns the range in form of declaration
+func rangeText(r parser.Range) string {
+	switch {
+	case r.Max:
+		return strconv.Itoa(r.Start) + " to max"
+	case r.Start == r.End:
+		return strconv.Itoa(r.Start)
+	}
+	return strconv.Itoa(r.Start) + " to " + strconv.Itoa(r.End)
+}
+
+// streamText returns the stream keyword with a trailing space if streaming
+func streamText(stream bool) string {
+	if stream {
+		return "stream "
+	}
+	return ""
+}
+
+// pad returns the text padded with spaces to the width
+func pad(text string, width int) string {
+	if len(text) >= width {
+		return text
+	}
+	return text + strings.Repeat(" ", width-len(text))
+}
+
+// isBlock reports whether the declaration has a body
+func isBlock(decl parser.Decl) bool {
+	switch d := decl.(type) {
+	case *parser.Message, *parser.Enum, *parser.Service, *parser.Extend:
+		return true
+	case *parser.Field:
+		return d.Group != nil
+	}
+	return false
+}
+
+// isSyntaxDecl reports whether the declaration is the syntax statement
+func isSyntaxDecl(decl parser.Decl) bool {
+	_, ok := decl.(*parser.Syntax)
+	return ok
+}
@@ -0,0 +1,122 @@
+package format
+
+import (
+	"testing"
+)
+
+const unformatted = `// Copyright header
+
+syntax="proto3";
+import "google/protobuf/timestamp.proto";
+package api.v1;
+import public "api/common.proto";
+option (gogoproto.marshaler_all) = true;
+option go_package="example.com/api/v1;v1";
+option java_multiple_files = true;
+
+// User represents a user
+message User { // open
+	// id of the user
+	int64 id=1;
+  string display_name = 2 [(gogoproto.moretags)="db:\"name\"", deprecated=true]; // trailing
+  map<string,string> labels = 3;
+	option deprecated = true;
+
+    repeated   string tags = 4;
+  google.protobuf.Timestamp created_at = 5;
+  oneof contact { string email = 6; string phone = 7; }
+  reserved 8, 10 to 12, 100 to max;
+  reserved "old";
+  /*
+   * detached block comment
+   */
+
+  message Empty {}
+  // end of user
+}
+enum Status { STATUS_UNSPECIFIED = 0; STATUS_ACTIVE = 1; STATUS_DELETED = -1 [deprecated = true]; }
+service UserService {
+  rpc GetUser ( GetUserRequest ) returns ( User );
+  rpc Watch(stream WatchRequest) returns (stream User) { option deprecated = true; }
+}
+// end of file
+`
+
+const formatted = `// Copyright header
+
+syntax = "proto3";
+
+package api.v1;
+
+import public "api/common.proto";
+import "google/protobuf/timestamp.proto";
+
+option go_package = "example.com/api/v1;v1";
+option java_multiple_files = true;
+option (gogoproto.marshaler_all) = true;
+
+// User represents a user
+message User { // open
+  option deprecated = true;
+
+  // id of the user
+  int64 id                   = 1;
+  string display_name        = 2 [(gogoproto.moretags) = "db:\"name\"", deprecated = true]; // trailing
+  map<string, string> labels = 3;
+
+  repeated string tags                 = 4;
+  google.protobuf.Timestamp created_at = 5;
+  oneof contact {
+    string email = 6;
+    string phone = 7;
+  }
+  reserved 8, 10 to 12, 100 to max;
+  reserved "old";
+  /*
+   * detached block comment
+   */
+
+  message Empty {}
+  // end of user
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_ACTIVE      = 1;
+  STATUS_DELETED     = -1 [deprecated = true];
+}
+
+service UserService {
+  rpc GetUser(GetUserRequest) returns (User);
+  rpc Watch(stream WatchRequest) returns (stream User) {
+    option deprecated = true;
+  }
+}
+
+// end of file
+`
+
+func TestSource(t *testing.T) {
+	out, err := Source("user.proto", []byte(unformatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != formatted {
+		t.Errorf("Source() =\n%s", out)
+	}
+
+	again, err := Source("user.proto", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != formatted {
+		t.Errorf("Source() is not idempotent:\n%s", again)
+	}
+}
+
+func TestSourceHeaderOnly(t *testing.T) {
+	source := "syntax = \"proto3\";\n\npackage api;\n"
+	if out, err := Source("api.proto", []byte(source)); err != nil || string(out) != source {
+		t.Errorf("Source() = %q, %v", out, err)
+	}
+}
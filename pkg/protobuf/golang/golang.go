@@ -0,0 +1,49 @@
+package golang
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	Namespace     = "google.golang.org/protobuf"
+	GrpcNamespace = "google.golang.org/grpc"
+)
+
+var (
+	// Plugins maps the plugin executables to their go packages
+	Plugins = map[string]string{
+		"protoc-gen-go":      Namespace + "/cmd/protoc-gen-go",
+		"protoc-gen-go-grpc": GrpcNamespace + "/cmd/protoc-gen-go-grpc",
+	}
+
+	// ErrModuleNotFound represents the go.mod has no module directive
+	ErrModuleNotFound = errors.New("golang: module directive not found")
+)
+
+// ModulePath returns the module path declared in go.mod of dir
+func ModulePath(dir string) (string, error) {
+	file, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module") {
+			if module := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module")), `"`); module != "" {
+				return module, nil
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrModuleNotFound
+}
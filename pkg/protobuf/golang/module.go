@@ -0,0 +1,294 @@
+package golang
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrNoModule represents no go.mod found from the directory up to the root
+	ErrNoModule = errors.New("golang: go.mod not found")
+)
+
+// Module represents a go module in the build list
+type Module struct {
+	// module path
+	Path string
+
+	// module version, empty for the main module
+	Version string
+
+	// directory holding files of the module, empty if not downloaded
+	Dir string
+
+	// whether is the main module
+	Main bool
+
+	// the replacement of the module
+	Replace *Module
+}
+
+// SourceDir returns directory of the module, the replacement is preferred
+func (m *Module) SourceDir() string {
+	if m.Replace != nil && m.Replace.Dir != "" {
+		return m.Replace.Dir
+	}
+	return m.Dir
+}
+
+// FindModuleRoot lookup the directory contains go.mod from dir up to the root
+func FindModuleRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if ok, _ := fs.IsFile(filepath.Join(dir, "go.mod")); ok {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNoModule
+		}
+		dir = parent
+	}
+}
+
+// ListModules returns the build list of the main module in dir, resolved
+// from the module cache by the go command
+func ListModules(dir string) ([]*Module, error) {
+	compiler, err := exec.LookPath("go")
+	if err != nil {
+		return nil, errors.New("golang: go compiler not found")
+	}
+
+	// -mod=readonly on command line overrides only the -mod of GOFLAGS,
+	// so go.mod and go.sum are never rewritten
+	cmd := exec.Command(compiler, "list", "-mod=readonly", "-m", "-json", "all")
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("golang: list modules error: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	var modules []*Module
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		module := &Module{}
+		if err := decoder.Decode(module); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
+
+// LinkModules creates a tree under root which every module is placed at
+// its module path, so that the imports prefixed by module path are resolved
+// with the returned directory as include path. The tree is named by the
+// digest of modules and reused until they change, and it is built aside
+// then renamed into place, so that concurrent processes never see a
+// partial tree. Modules are linked by symlink, or only the protobuf files
+// are copied if symlink not supported, then the size and modification time
+// of the copied files are part of the digest. The main module is edited
+// in place, so it is linked but never copied. The directories of a
+// module holding nested modules are created instead of linked, so every
+// nested module is taken from its own directory at its own version
+func LinkModules(root string, modules []*Module) (string, error) {
+	if err := os.MkdirAll(root, fs.DirectoryPerm); err != nil {
+		return "", err
+	}
+	symlinked := canSymlink(root)
+
+	dirs := make(map[string]string)
+	var paths []string
+	for _, module := range modules {
+		if module.SourceDir() != "" && (symlinked || !module.Main) {
+			dirs[module.Path] = module.SourceDir()
+			paths = append(paths, module.Path)
+		}
+	}
+	sort.Strings(paths)
+
+	digest := sha256.New()
+	for _, path := range paths {
+		_, _ = fmt.Fprintf(digest, "%s\x00%s\n", path, dirs[path])
+		if !symlinked {
+			if err := stampProtoFiles(digest, dirs[path]); err != nil {
+				return "", err
+			}
+		}
+	}
+	tree := filepath.Join(root, hex.EncodeToString(digest.Sum(nil)[:8]))
+	if ok, _ := fs.IsDir(tree); ok {
+		return tree, nil
+	}
+
+	temp, err := ioutil.TempDir(root, ".link")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(temp) }()
+
+	for _, path := range paths {
+		dst := filepath.Join(temp, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(dst), fs.DirectoryPerm); err != nil {
+			return "", err
+		}
+		if err := linkModule(dirs[path], dst, path, paths, symlinked); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.Rename(temp, tree); err != nil {
+		if ok, _ := fs.IsDir(tree); !ok {
+			return "", err
+		}
+	}
+	return tree, nil
+}
+
+// ModuleTrees returns the trees created by LinkModules under root, the
+// trees being built are excluded
+func ModuleTrees(root string) ([]string, error) {
+	infos, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var trees []string
+	for _, info := range infos {
+		if info.IsDir() && !strings.HasPrefix(info.Name(), ".") {
+			trees = append(trees, filepath.Join(root, info.Name()))
+		}
+	}
+	return trees, nil
+}
+
+// symlink creates dst as a symbolic link to src
+var symlink = os.Symlink
+
+// canSymlink reports whether symlink is supported in dir
+func canSymlink(dir string) bool {
+	probe, err := ioutil.TempDir(dir, ".probe")
+	if err != nil {
+		return false
+	}
+	defer func() { _ = os.RemoveAll(probe) }()
+
+	return symlink(probe, filepath.Join(probe, "link")) == nil
+}
+
+// stampProtoFiles writes the name, size and modification time of the
+// protobuf files in dir into w
+func stampProtoFiles(w io.Writer, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".proto" {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(w, "file\x00%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+}
+
+// linkModule links src into dst of the module path, the directories
+// containing any of the nested modules are created with their entries
+// linked one by one, and the nested modules themselves are skipped
+func linkModule(src, dst, path string, modules []string, symlinked bool) error {
+	if !hasNested(path, modules) {
+		return link(src, dst, symlinked)
+	}
+
+	if err := os.MkdirAll(dst, fs.DirectoryPerm); err != nil {
+		return err
+	}
+
+	infos, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		child := path + "/" + info.Name()
+		if isModule(child, modules) {
+			continue
+		}
+
+		if info.IsDir() {
+			err = linkModule(filepath.Join(src, info.Name()), filepath.Join(dst, info.Name()), child, modules, symlinked)
+		} else {
+			err = link(filepath.Join(src, info.Name()), filepath.Join(dst, info.Name()), symlinked)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// link creates symlink dst to src, or copy the protobuf files of src
+// into dst if symlink not supported
+func link(src, dst string, symlinked bool) error {
+	if symlinked {
+		return symlink(src, dst)
+	}
+
+	if ok, _ := fs.IsDir(src); ok {
+		return copyProtoFiles(src, dst)
+	} else if filepath.Ext(src) == ".proto" {
+		return fs.CopyFile(src, dst, fs.RegularFilePerm)
+	}
+	return nil
+}
+
+// hasNested reports whether any of the modules is nested in path
+func hasNested(path string, modules []string) bool {
+	for _, module := range modules {
+		if strings.HasPrefix(module, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isModule reports whether path is one of the modules
+func isModule(path string, modules []string) bool {
+	index := sort.SearchStrings(modules, path)
+	return index < len(modules) && modules[index] == path
+}
+
+// copyProtoFiles copy protobuf files in src into dst recursively
+func copyProtoFiles(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".proto" {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return fs.CopyFile(path, filepath.Join(dst, rel), fs.RegularFilePerm)
+	})
+}
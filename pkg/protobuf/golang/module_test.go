@@ -0,0 +1,127 @@
+package golang
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"protob/pkg/os/fs/fstest"
+	"sync"
+	"testing"
+)
+
+func TestLinkModules(t *testing.T) {
+	tmp := fstest.TempDir(t, map[string]string{
+		"cache/foo@v1.2.0/api/foo.proto":               "v2",
+		"cache/foo@v1.2.0/nested/stale.proto":          "stale",
+		"cache/foo/nested@v0.1.0/inner.proto":          "inner",
+		"cache/foo/nested/deep@v0.2.0/deep/deep.proto": "deep",
+		"cache/foo/nested@v0.1.0/deep/shallow.proto":   "shallow",
+		"local/bar/bar.proto":                          "bar",
+	})
+
+	modules := []*Module{
+		{Path: "example.com/foo", Version: "v1.2.0", Dir: filepath.Join(tmp, "cache/foo@v1.2.0")},
+		{Path: "example.com/foo/nested", Version: "v0.1.0", Dir: filepath.Join(tmp, "cache/foo/nested@v0.1.0")},
+		{Path: "example.com/foo/nested/deep", Version: "v0.2.0", Dir: filepath.Join(tmp, "cache/foo/nested/deep@v0.2.0")},
+		{Path: "example.com/bar", Version: "v1.0.0", Dir: filepath.Join(tmp, "cache/bar@v1.0.0"),
+			Replace: &Module{Path: "../bar", Dir: filepath.Join(tmp, "local/bar")}},
+		{Path: "example.com/missing", Version: "v1.0.0"},
+	}
+
+	tree, err := LinkModules(filepath.Join(tmp, "modules"), modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"example.com/foo/api/foo.proto":               "v2",
+		"example.com/foo/nested/inner.proto":          "inner",
+		"example.com/foo/nested/deep/deep/deep.proto": "deep",
+		"example.com/bar/bar.proto":                   "bar",
+	}
+	for file, content := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(tree, file))
+		if err != nil {
+			t.Errorf("%s: %s", file, err)
+		} else if string(data) != content {
+			t.Errorf("%s = %q, expected %q", file, data, content)
+		}
+	}
+
+	for _, file := range []string{"example.com/foo/nested/stale.proto", "example.com/foo/nested/deep/shallow.proto", "example.com/missing"} {
+		if _, err := os.Lstat(filepath.Join(tree, file)); err == nil {
+			t.Errorf("%s should not be linked", file)
+		}
+	}
+}
+
+func TestLinkModulesConcurrently(t *testing.T) {
+	tmp := fstest.TempDir(t, map[string]string{"cache/foo@v1.0.0/foo.proto": "foo"})
+	modules := []*Module{{Path: "example.com/foo", Version: "v1.0.0", Dir: filepath.Join(tmp, "cache/foo@v1.0.0")}}
+	root := filepath.Join(tmp, "modules")
+
+	var wg sync.WaitGroup
+	trees := make([]string, 8)
+	for i := range trees {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tree, err := LinkModules(root, modules)
+			if err != nil {
+				t.Error(err)
+			} else if _, err := ioutil.ReadFile(filepath.Join(tree, "example.com/foo/foo.proto")); err != nil {
+				t.Error(err)
+			}
+			trees[i] = tree
+		}(i)
+	}
+	wg.Wait()
+
+	for _, tree := range trees {
+		if tree != trees[0] {
+			t.Errorf("LinkModules() = %s, expected the same tree %s", tree, trees[0])
+		}
+	}
+
+	modules[0].Version, modules[0].Dir = "v1.1.0", filepath.Join(tmp, "cache/foo@v1.1.0")
+	if tree, err := LinkModules(root, modules); err != nil || tree == trees[0] {
+		t.Errorf("LinkModules() = %s, %v, expected a new tree of changed modules", tree, err)
+	}
+}
+
+func TestLinkModulesCopy(t *testing.T) {
+	tmp := fstest.TempDir(t, map[string]string{
+		"local/foo/foo.proto": "v1",
+		"project/api.proto":   "main",
+	})
+	modules := []*Module{
+		{Path: "example.com/project", Dir: filepath.Join(tmp, "project"), Main: true},
+		{Path: "example.com/foo", Version: "v1.0.0", Replace: &Module{Path: "../foo", Dir: filepath.Join(tmp, "local/foo")}},
+	}
+	root := filepath.Join(tmp, "modules")
+
+	defer func(link func(string, string) error) { symlink = link }(symlink)
+	symlink = func(string, string) error { return errors.New("symlink not supported") }
+
+	tree, err := LinkModules(root, modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(tree, "example.com/project")); err == nil {
+		t.Errorf("LinkModules() copied the main module, expected never copied")
+	}
+
+	fstest.WriteFiles(t, tmp, map[string]string{"local/foo/foo.proto": "v2 changed"})
+	changed, err := LinkModules(root, modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(changed, "example.com/foo/foo.proto")); err != nil || string(data) != "v2 changed" {
+		t.Errorf("LinkModules() copy = %q, %v, expected the changed file", data, err)
+	}
+
+	if trees, err := ModuleTrees(root); err != nil || len(trees) != 2 {
+		t.Errorf("ModuleTrees() = %v, %v, expected the stale and the current tree", trees, err)
+	}
+}
@@ -0,0 +1,105 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteText writes every file with its imports, the missing imports and
+// import cycles in human readable text
+func (g *Graph) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, node := range g.Nodes() {
+		b.WriteString(node.File)
+		if node.Target {
+			b.WriteString(" (target)")
+		}
+		b.WriteString("\n")
+
+		for _, file := range node.Imports {
+			b.WriteString("  -> " + file + "\n")
+		}
+		for _, name := range node.Missing {
+			b.WriteString("  -> " + name + " (missing)\n")
+		}
+	}
+
+	if cycles := g.Cycles(); len(cycles) != 0 {
+		b.WriteString("\ncycles:\n")
+		for _, cycle := range cycles {
+			b.WriteString("  " + strings.Join(cycle, " -> ") + "\n")
+		}
+	}
+	if missing := g.Missing(); len(missing) != 0 {
+		b.WriteString("\nmissing:\n")
+		for _, name := range missing {
+			b.WriteString("  " + name + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the graph as a json object of nodes, cycles and missing
+func (g *Graph) WriteJSON(w io.Writer) error {
+	cycles := g.Cycles()
+	if cycles == nil {
+		cycles = make([][]string, 0)
+	}
+	missing := g.Missing()
+	if missing == nil {
+		missing = make([]string, 0)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Nodes   []*Node    `json:"nodes"`
+		Cycles  [][]string `json:"cycles"`
+		Missing []string   `json:"missing"`
+	}{g.Nodes(), cycles, missing})
+}
+
+// WriteDot writes the graph in graphviz dot language, the targets are
+// boxed, the missing imports are dashed and edges of cycles are red
+func (g *Graph) WriteDot(w io.Writer) error {
+	inCycle := make(map[[2]string]bool)
+	for _, cycle := range g.Cycles() {
+		for i := 0; i+1 < len(cycle); i++ {
+			inCycle[[2]string{cycle[i], cycle[i+1]}] = true
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph imports {\n")
+	b.WriteString("  node [shape=ellipse];\n")
+	for _, node := range g.Nodes() {
+		if node.Target {
+			_, _ = fmt.Fprintf(&b, "  %s [shape=box];\n", strconv.Quote(node.File))
+		}
+	}
+	for _, name := range g.Missing() {
+		_, _ = fmt.Fprintf(&b, "  %s [style=dashed, color=gray];\n", strconv.Quote(name))
+	}
+
+	for _, node := range g.Nodes() {
+		for _, file := range node.Imports {
+			attrs := ""
+			if inCycle[[2]string{node.File, file}] {
+				attrs = " [color=red]"
+			}
+			_, _ = fmt.Fprintf(&b, "  %s -> %s%s;\n", strconv.Quote(node.File), strconv.Quote(file), attrs)
+		}
+		for _, name := range node.Missing {
+			_, _ = fmt.Fprintf(&b, "  %s -> %s [style=dashed];\n", strconv.Quote(node.File), strconv.Quote(name))
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
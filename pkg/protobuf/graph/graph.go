@@ -0,0 +1,224 @@
+package graph
+
+import (
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf"
+	"sort"
+)
+
+// Node represents a protobuf file in the import graph
+type Node struct {
+	// path of the protobuf file
+	File string `json:"file"`
+
+	// whether the file is a target, otherwise imported by targets
+	Target bool `json:"target"`
+
+	// files imported by the file which resolved from include paths
+	Imports []string `json:"imports"`
+
+	// import paths of the file unable to resolve
+	Missing []string `json:"missing,omitempty"`
+}
+
+// Graph represents the transitive imports of protobuf files
+type Graph struct {
+	nodes map[string]*Node
+}
+
+// Add scans targets and the files imported by them transitively, the
+// imports are resolved from include paths in order
+func (g *Graph) Add(includes []string, targets []string) error {
+	var queue []string
+	for _, target := range targets {
+		file := fs.Join(target)
+		if node, ok := g.nodes[file]; ok {
+			node.Target = true
+			continue
+		}
+
+		g.nodes[file] = &Node{File: file, Target: true}
+		queue = append(queue, file)
+	}
+
+	for len(queue) != 0 {
+		node := g.nodes[queue[0]]
+		queue = queue[1:]
+
+		source, err := protobuf.ScanFile(node.File)
+		if err != nil {
+			return err
+		}
+
+		node.Imports, node.Missing = make([]string, 0), nil
+		for _, name := range source.Imports {
+			path, ok := protobuf.ResolveImport(includes, name)
+			if !ok {
+				node.Missing = append(node.Missing, name)
+				continue
+			}
+
+			node.Imports = append(node.Imports, path)
+			if _, ok := g.nodes[path]; !ok {
+				g.nodes[path] = &Node{File: path}
+				queue = append(queue, path)
+			}
+		}
+	}
+	return nil
+}
+
+// Nodes returns all files in the graph sorted by path
+func (g *Graph) Nodes() []*Node {
+	nodes := make([]*Node, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].File < nodes[j].File })
+	return nodes
+}
+
+// Missing returns the import paths unable to resolve of all files
+func (g *Graph) Missing() []string {
+	var missing []string
+	seen := make(map[string]bool)
+	for _, node := range g.nodes {
+		for _, name := range node.Missing {
+			if !seen[name] {
+				seen[name] = true
+				missing = append(missing, name)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Cycles returns every import cycle in the graph, each cycle starts and
+// ends with the smallest file in it, e.g. [a.proto b.proto a.proto]
+func (g *Graph) Cycles() [][]string {
+	var cycles [][]string
+	for _, component := range g.components() {
+		if len(component) == 1 && !contains(g.nodes[component[0]].Imports, component[0]) {
+			continue
+		}
+		cycles = append(cycles, g.cycleIn(component))
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// components returns the strongly connected components of the graph
+// by tarjan algorithm, files in each component are sorted
+func (g *Graph) components() [][]string {
+	var (
+		index      int
+		stack      []string
+		components [][]string
+	)
+	indexes, lowlinks, onStack := make(map[string]int), make(map[string]int), make(map[string]bool)
+
+	var connect func(file string)
+	connect = func(file string) {
+		indexes[file], lowlinks[file] = index, index
+		index++
+		stack = append(stack, file)
+		onStack[file] = true
+
+		for _, next := range g.nodes[file].Imports {
+			if _, visited := indexes[next]; !visited {
+				connect(next)
+				lowlinks[file] = min(lowlinks[file], lowlinks[next])
+			} else if onStack[next] {
+				lowlinks[file] = min(lowlinks[file], indexes[next])
+			}
+		}
+
+		if lowlinks[file] == indexes[file] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == file {
+					break
+				}
+			}
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+
+	for _, node := range g.Nodes() {
+		if _, visited := indexes[node.File]; !visited {
+			connect(node.File)
+		}
+	}
+	return components
+}
+
+// cycleIn returns the shortest cycle through the smallest file of the
+// strongly connected component
+func (g *Graph) cycleIn(component []string) []string {
+	start := component[0]
+	members := make(map[string]bool)
+	for _, file := range component {
+		members[file] = true
+	}
+
+	parents := map[string]string{}
+	queue := []string{start}
+	for len(queue) != 0 {
+		file := queue[0]
+		queue = queue[1:]
+
+		for _, next := range g.nodes[file].Imports {
+			if next == start {
+				cycle := []string{start}
+				for at := file; at != start; at = parents[at] {
+					cycle = append(cycle, at)
+				}
+				reverse(cycle[1:])
+				return append(cycle, start)
+			}
+
+			if _, seen := parents[next]; !seen && members[next] {
+				parents[next] = file
+				queue = append(queue, next)
+			}
+		}
+	}
+	return append(component, start)
+}
+
+// New creates an empty import graph
+func New() *Graph {
+	return &Graph{nodes: make(map[string]*Node)}
+}
+
+// contains reports whether the file in files
+func contains(files []string, file string) bool {
+	for _, f := range files {
+		if f == file {
+			return true
+		}
+	}
+	return false
+}
+
+// reverse reverses the files in place
+func reverse(files []string) {
+	for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+		files[i], files[j] = files[j], files[i]
+	}
+}
+
+// min returns the smaller one of a and b
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
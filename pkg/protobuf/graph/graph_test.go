@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"bytes"
+	"protob/pkg/os/fs/fstest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	fstest.Chdir(t, fstest.TempDir(t, map[string]string{
+		"api/user.proto":    `import "api/common.proto"; import "google/protobuf/empty.proto";`,
+		"api/order.proto":   `import "api/user.proto"; import "shared/a.proto";`,
+		"api/common.proto":  `syntax = "proto3";`,
+		"shared/a.proto":    `import "shared/b.proto";`,
+		"shared/b.proto":    `import "shared/c.proto";`,
+		"shared/c.proto":    `import "shared/a.proto"; import "shared/self.proto";`,
+		"shared/self.proto": `import "shared/self.proto";`,
+	}))
+	g := New()
+
+	if err := g.Add([]string{"."}, []string{"api/user.proto", "api/order.proto"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	for _, node := range g.Nodes() {
+		files = append(files, node.File)
+	}
+	expectedFiles := []string{
+		"api/common.proto", "api/order.proto", "api/user.proto",
+		"shared/a.proto", "shared/b.proto", "shared/c.proto", "shared/self.proto",
+	}
+	if !reflect.DeepEqual(files, expectedFiles) {
+		t.Errorf("Nodes() = %v, expected %v", files, expectedFiles)
+	}
+
+	if missing := g.Missing(); !reflect.DeepEqual(missing, []string{"google/protobuf/empty.proto"}) {
+		t.Errorf("Missing() = %v", missing)
+	}
+
+	expectedCycles := [][]string{
+		{"shared/a.proto", "shared/b.proto", "shared/c.proto", "shared/a.proto"},
+		{"shared/self.proto", "shared/self.proto"},
+	}
+	if cycles := g.Cycles(); !reflect.DeepEqual(cycles, expectedCycles) {
+		t.Errorf("Cycles() = %v, expected %v", cycles, expectedCycles)
+	}
+}
+
+func TestGraphFormat(t *testing.T) {
+	fstest.Chdir(t, fstest.TempDir(t, map[string]string{
+		"a.proto": `import "b.proto"; import "missing.proto";`,
+		"b.proto": `import "a.proto";`,
+	}))
+	g := New()
+
+	if err := g.Add([]string{"."}, []string{"a.proto"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var text bytes.Buffer
+	if err := g.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	expectedText := `a.proto (target)
+  -> b.proto
+  -> missing.proto (missing)
+b.proto
+  -> a.proto
+
+cycles:
+  a.proto -> b.proto -> a.proto
+
+missing:
+  missing.proto
+`
+	if text.String() != expectedText {
+		t.Errorf("WriteText() = %q", text.String())
+	}
+
+	var dot bytes.Buffer
+	if err := g.WriteDot(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`"a.proto" [shape=box];`,
+		`"a.proto" -> "b.proto" [color=red];`,
+		`"a.proto" -> "missing.proto" [style=dashed];`,
+	} {
+		if !strings.Contains(dot.String(), line) {
+			t.Errorf("WriteDot() missing %q:\n%s", line, dot.String())
+		}
+	}
+}
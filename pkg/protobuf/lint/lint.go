@@ -0,0 +1,129 @@
+package lint
+
+import (
+	"fmt"
+	"protob/pkg/protobuf/parser"
+	"sort"
+)
+
+// Problem represents a violation of lint rule
+type Problem struct {
+	// path of the file
+	File string `json:"file"`
+
+	// position of the declaration violates the rule
+	Line   int `json:"line"`
+	Column int `json:"column"`
+
+	// name of the rule
+	Rule string `json:"rule"`
+
+	Message string `json:"message"`
+}
+
+// String returns the problem in form of file:line:column: message (rule)
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", p.File, p.Line, p.Column, p.Message, p.Rule)
+}
+
+// Reporter reports a problem at position of the declaration
+type Reporter func(pos parser.Position, format string, args ...interface{})
+
+// Rule represents a check on the syntax tree of protobuf file
+type Rule struct {
+	// unique name of the rule, e.g. field_lower_snake_case
+	Name string
+
+	// description of the rule
+	Description string
+
+	// whether the rule is enabled by default
+	Default bool
+
+	// check reports problems of the file
+	check func(file *parser.File, report Reporter)
+}
+
+// Linter checks protobuf files by the enabled rules
+type Linter struct {
+	rules []*Rule
+}
+
+// Rules returns the enabled rules sorted by name
+func (l *Linter) Rules() []*Rule {
+	return l.rules
+}
+
+// Lint returns problems of the file sorted by position
+func (l *Linter) Lint(file *parser.File) []*Problem {
+	var problems []*Problem
+	for _, rule := range l.rules {
+		rule.check(file, func(pos parser.Position, format string, args ...interface{}) {
+			problems = append(problems, &Problem{
+				File:    file.Name,
+				Line:    pos.Line,
+				Column:  pos.Column,
+				Rule:    rule.Name,
+				Message: fmt.Sprintf(format, args...),
+			})
+		})
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+	return problems
+}
+
+// Selection represents the rules enabled and disabled by one source, e.g.
+// the configuration file or the flags
+type Selection struct {
+	// rules to enable, "all" for every rule
+	Enable []string
+
+	// rules to disable, "all" for every rule
+	Disable []string
+}
+
+// New creates a linter with the default rules, then applies selections in
+// order so the later overrides the earlier, the rules of each selection
+// are disabled first and then enabled by name
+func New(selections ...Selection) (*Linter, error) {
+	enabled := make(map[string]bool)
+	for _, rule := range Rules {
+		enabled[rule.Name] = rule.Default
+	}
+
+	for _, selection := range selections {
+		for _, names := range []struct {
+			names []string
+			value bool
+		}{{selection.Disable, false}, {selection.Enable, true}} {
+			for _, name := range names.names {
+				if name == "all" {
+					for rule := range enabled {
+						enabled[rule] = names.value
+					}
+					continue
+				}
+
+				if _, ok := enabled[name]; !ok {
+					return nil, fmt.Errorf("lint: unknown rule '%s'", name)
+				}
+				enabled[name] = names.value
+			}
+		}
+	}
+
+	linter := &Linter{}
+	for _, rule := range Rules {
+		if enabled[rule.Name] {
+			linter.rules = append(linter.rules, rule)
+		}
+	}
+	sort.Slice(linter.rules, func(i, j int) bool { return linter.rules[i].Name < linter.rules[j].Name })
+	return linter, nil
+}
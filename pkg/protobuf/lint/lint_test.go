@@ -0,0 +1,123 @@
+package lint
+
+import (
+	"protob/pkg/protobuf/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSource = `syntax = "proto3";
+
+package Foo.bar;
+
+message user_info {
+  // id of the user
+  int64 ID = 1;
+  string name = 2; // name of the user
+
+  oneof Contact {
+    string email = 3;
+  }
+}
+
+enum Status {
+  ACTIVE = 0;
+  deleted = 1;
+}
+
+enum Kind {
+  KIND_UNKNOWN = 1;
+}
+
+// Users manages users
+service Users {
+  rpc get_user(user_info) returns (user_info);
+}
+`
+
+func lintSource(t *testing.T, enable, disable []string) []string {
+	file, err := parser.Parse("user.proto", []byte(testSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	linter, err := New(Selection{Enable: enable, Disable: disable})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var problems []string
+	for _, problem := range linter.Lint(file) {
+		problems = append(problems, problem.String())
+	}
+	return problems
+}
+
+func TestLint(t *testing.T) {
+	expected := []string{
+		"user.proto:3:1: option go_package should be declared (go_package_defined)",
+		"user.proto:3:1: package 'Foo.bar' should be lower_snake_case (package_lower_snake_case)",
+		"user.proto:5:1: message 'user_info' should be PascalCase (message_pascal_case)",
+		"user.proto:7:3: field 'ID' should be lower_snake_case (field_lower_snake_case)",
+		"user.proto:10:3: oneof 'Contact' should be lower_snake_case (oneof_lower_snake_case)",
+		"user.proto:16:3: zero value of enum 'Status' should be named STATUS_UNSPECIFIED (enum_zero_value_unspecified)",
+		"user.proto:17:3: enum value 'deleted' should be UPPER_SNAKE_CASE (enum_value_upper_snake_case)",
+		"user.proto:21:3: first value of enum 'Kind' should be zero (enum_zero_value_unspecified)",
+		"user.proto:26:3: rpc 'get_user' should be PascalCase (rpc_pascal_case)",
+	}
+
+	if problems := lintSource(t, nil, nil); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Lint() =\n%s", joinLines(problems))
+	}
+}
+
+func TestLintConfigurable(t *testing.T) {
+	expected := []string{
+		"user.proto:5:1: message 'user_info' should have a comment (comment_message)",
+		"user.proto:11:5: field 'email' should have a comment (comment_field)",
+		"user.proto:15:1: enum 'Status' should have a comment (comment_enum)",
+		"user.proto:20:1: enum 'Kind' should have a comment (comment_enum)",
+		"user.proto:25:1: service 'Users' should be suffixed with Service (service_suffix)",
+		"user.proto:26:3: rpc 'get_user' should have a comment (comment_rpc)",
+	}
+
+	enable := []string{"comment_message", "comment_field", "comment_enum", "comment_service", "comment_rpc", "service_suffix"}
+	if problems := lintSource(t, enable, []string{"all"}); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Lint() =\n%s", joinLines(problems))
+	}
+
+	if problems := lintSource(t, nil, []string{"all"}); problems != nil {
+		t.Errorf("Lint() with all disabled =\n%s", joinLines(problems))
+	}
+
+	linter, err := New(Selection{Enable: []string{"all"}})
+	if err != nil || len(linter.Rules()) != len(Rules) {
+		t.Errorf("New(all) enabled %d rules, error %v", len(linter.Rules()), err)
+	}
+
+	if _, err := New(Selection{Enable: []string{"no_such_rule"}}); err == nil {
+		t.Error("unknown rule should be rejected")
+	}
+
+	config := Selection{Enable: []string{"comment_service"}}
+	if linter, err := New(config, Selection{Disable: []string{"comment_service"}}); err != nil || enabledRule(linter, "comment_service") {
+		t.Errorf("New() with comment_service disabled by the later selection = %v, %v", linter, err)
+	}
+	if linter, err := New(Selection{Disable: []string{"all"}}, Selection{Enable: []string{"comment_service"}}); err != nil || len(linter.Rules()) != 1 {
+		t.Errorf("New() with only comment_service enabled by the later selection = %v, %v", linter, err)
+	}
+}
+
+func enabledRule(linter *Linter, name string) bool {
+	for _, rule := range linter.Rules() {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func joinLines(lines []string) string {
+	return strings.Join(lines, "\n")
+}
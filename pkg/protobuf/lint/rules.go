@@ -0,0 +1,315 @@
+package lint
+
+import (
+	"protob/pkg/protobuf/parser"
+	"regexp"
+	"strings"
+)
+
+var (
+	pascalCase      = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+	lowerSnakeCase  = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	upperSnakeCase  = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+	packageSegments = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// Rules are all of the lint rules
+var Rules = []*Rule{
+	{
+		Name:        "package_defined",
+		Description: "files must declare a package",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			if file.Package() == nil {
+				report(parser.Position{Line: 1, Column: 1}, "package should be declared")
+			}
+		},
+	},
+	{
+		Name:        "package_lower_snake_case",
+		Description: "package names must be dot separated lower_snake_case",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			if pkg := file.Package(); pkg != nil {
+				for _, segment := range strings.Split(pkg.Name, ".") {
+					if !packageSegments.MatchString(segment) {
+						report(pkg.Pos, "package '%s' should be lower_snake_case", pkg.Name)
+						return
+					}
+				}
+			}
+		},
+	},
+	{
+		Name:        "go_package_defined",
+		Description: "files must declare the go_package option",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			if option := file.Option("go_package"); option == nil || option.Value.String == "" {
+				pos := parser.Position{Line: 1, Column: 1}
+				if pkg := file.Package(); pkg != nil {
+					pos = pkg.Pos
+				}
+				report(pos, "option go_package should be declared")
+			}
+		},
+	},
+	{
+		Name:        "message_pascal_case",
+		Description: "message names must be PascalCase",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			for _, message := range allMessages(file) {
+				if !pascalCase.MatchString(message.Name) {
+					report(message.Pos, "message '%s' should be PascalCase", message.Name)
+				}
+			}
+		},
+	},
+	{
+		Name:        "field_lower_snake_case",
+		Description: "field names must be lower_snake_case",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			for _, field := range allFields(file) {
+				if field.Group == nil && !lowerSnakeCase.MatchString(field.Name) {
+					report(field.Pos, "field '%s' should be lower_snake_case", field.Name)
+				}
+			}
+		},
+	},
+	{
+		Name:        "oneof_lower_snake_case",
+		Description: "oneof names must be lower_snake_case",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			for _, message := range allMessages(file) {
+				for _, oneof := range message.Oneofs() {
+					if !lowerSnakeCase.MatchString(oneof.Name) {
+						report(oneof.Pos, "oneof '%s' should be lower_snake_case", oneof.Name)
+					}
+				}
+			}
+		},
+	},
+	{
+		Name:        "enum_pascal_case",
+		Description: "enum names must be PascalCase",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			for _, enum := range allEnums(file) {
+				if !pascalCase.MatchString(enum.Name) {
+					report(enum.Pos, "enum '%s' should be PascalCase", enum.Name)
+				}
+			}
+		},
+	},
+	{
+		Name:        "enum_value_upper_snake_case",
+		Description: "enum value names must be UPPER_SNAKE_CASE",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			for _, enum := range allEnums(file) {
+				for _, value := range enum.Values() {
+					if !upperSnakeCase.MatchString(value.Name) {
+						report(value.Pos, "enum value '%s' should be UPPER_SNAKE_CASE", value.Name)
+					}
+				}
+			}
+		},
+	},
+	{
+		Name:        "enum_zero_value_unspecified",
+		Description: "the first enum value must be zero and suffixed with _UNSPECIFIED",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			for _, enum := range allEnums(file) {
+				values := enum.Values()
+				if len(values) == 0 {
+					continue
+				}
+
+				if first := values[0]; first.Number != 0 {
+					report(first.Pos, "first value of enum '%s' should be zero", enum.Name)
+				} else if !strings.HasSuffix(first.Name, "_UNSPECIFIED") {
+					report(first.Pos, "zero value of enum '%s' should be named %s_UNSPECIFIED", enum.Name, toUpperSnake(enum.Name))
+				}
+			}
+		},
+	},
+	{
+		Name:        "service_pascal_case",
+		Description: "service names must be PascalCase",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			for _, service := range file.Services() {
+				if !pascalCase.MatchString(service.Name) {
+					report(service.Pos, "service '%s' should be PascalCase", service.Name)
+				}
+			}
+		},
+	},
+	{
+		Name:        "service_suffix",
+		Description: "service names must be suffixed with Service",
+		check: func(file *parser.File, report Reporter) {
+			for _, service := range file.Services() {
+				if !strings.HasSuffix(service.Name, "Service") {
+					report(service.Pos, "service '%s' should be suffixed with Service", service.Name)
+				}
+			}
+		},
+	},
+	{
+		Name:        "rpc_pascal_case",
+		Description: "rpc names must be PascalCase",
+		Default:     true,
+		check: func(file *parser.File, report Reporter) {
+			for _, service := range file.Services() {
+				for _, rpc := range service.RPCs() {
+					if !pascalCase.MatchString(rpc.Name) {
+						report(rpc.Pos, "rpc '%s' should be PascalCase", rpc.Name)
+					}
+				}
+			}
+		},
+	},
+	{
+		Name:        "comment_message",
+		Description: "messages must have comments",
+		check: func(file *parser.File, report Reporter) {
+			for _, message := range allMessages(file) {
+				if !commented(&message.Element) {
+					report(message.Pos, "message '%s' should have a comment", message.Name)
+				}
+			}
+		},
+	},
+	{
+		Name:        "comment_field",
+		Description: "fields must have comments",
+		check: func(file *parser.File, report Reporter) {
+			for _, field := range allFields(file) {
+				if !commented(&field.Element) {
+					report(field.Pos, "field '%s' should have a comment", field.Name)
+				}
+			}
+		},
+	},
+	{
+		Name:        "comment_enum",
+		Description: "enums must have comments",
+		check: func(file *parser.File, report Reporter) {
+			for _, enum := range allEnums(file) {
+				if !commented(&enum.Element) {
+					report(enum.Pos, "enum '%s' should have a comment", enum.Name)
+				}
+			}
+		},
+	},
+	{
+		Name:        "comment_service",
+		Description: "services must have comments",
+		check: func(file *parser.File, report Reporter) {
+			for _, service := range file.Services() {
+				if !commented(&service.Element) {
+					report(service.Pos, "service '%s' should have a comment", service.Name)
+				}
+			}
+		},
+	},
+	{
+		Name:        "comment_rpc",
+		Description: "rpcs must have comments",
+		check: func(file *parser.File, report Reporter) {
+			for _, service := range file.Services() {
+				for _, rpc := range service.RPCs() {
+					if !commented(&rpc.Element) {
+						report(rpc.Pos, "rpc '%s' should have a comment", rpc.Name)
+					}
+				}
+			}
+		},
+	},
+}
+
+// allMessages returns messages in the file, include the nested ones and groups
+func allMessages(file *parser.File) []*parser.Message {
+	var messages []*parser.Message
+	var walk func(decls []parser.Decl)
+	walk = func(decls []parser.Decl) {
+		for _, decl := range decls {
+			switch d := decl.(type) {
+			case *parser.Message:
+				messages = append(messages, d)
+				walk(d.Decls)
+			case *parser.Field:
+				if d.Group != nil {
+					messages = append(messages, d.Group)
+					walk(d.Group.Decls)
+				}
+			case *parser.Oneof:
+				walk(d.Decls)
+			}
+		}
+	}
+	walk(file.Decls)
+	return messages
+}
+
+// allEnums returns enums in the file, include the nested ones
+func allEnums(file *parser.File) []*parser.Enum {
+	enums := file.Enums()
+	for _, message := range allMessages(file) {
+		enums = append(enums, message.Enums()...)
+	}
+	return enums
+}
+
+// allFields returns fields of every message and extend in the file
+func allFields(file *parser.File) []*parser.Field {
+	var fields []*parser.Field
+	for _, extend := range file.Extends() {
+		fields = append(fields, extend.Fields()...)
+	}
+	for _, message := range allMessages(file) {
+		fields = append(fields, message.Fields()...)
+		for _, extend := range message.Extends() {
+			fields = append(fields, extend.Fields()...)
+		}
+	}
+	return fields
+}
+
+// commented reports whether the declaration has non-empty leading or
+// trailing comments
+func commented(element *parser.Element) bool {
+	comments := append([]*parser.Comment{}, element.Comments.Attached(element.Pos.Line)...)
+	if element.Comments.Trailing != nil {
+		comments = append(comments, element.Comments.Trailing)
+	}
+
+	for _, comment := range comments {
+		text := strings.TrimPrefix(comment.Text, "//")
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		if strings.Trim(text, " \t\r\n*/") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// toUpperSnake converts PascalCase name into UPPER_SNAKE_CASE
+func toUpperSnake(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		if i > 0 && c >= 'A' && c <= 'Z' {
+			prev := name[i-1]
+			if prev >= 'a' && prev <= 'z' || prev >= '0' && prev <= '9' {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(c)
+	}
+	return strings.ToUpper(b.String())
+}
@@ -0,0 +1,118 @@
+package protobuf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf/native"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// BackendProtoc is the name of the backend runs protoc
+	BackendProtoc = "protoc"
+	// BackendNative is the name of the native backend
+	BackendNative = "native"
+)
+
+// NativeCompiler represents the backend parses and links protobuf files
+// in process, the plugins are invoked directly without protoc
+type NativeCompiler struct{}
+
+// Compile compile protobuf files by the native backend
+func (c *NativeCompiler) Compile(ctx context.Context, targets []string, runtime *CompilerRuntime) error {
+	return compileNative(ctx, targets, runtime)
+}
+
+// Version returns the version of the native backend
+func (c *NativeCompiler) Version() string {
+	return "protob " + BackendNative
+}
+
+// Capabilities returns none of the capabilities, the external arguments,
+// insertion points and built-in generators are unsupported
+func (c *NativeCompiler) Capabilities() Capabilities {
+	return 0
+}
+
+// NewNativeCompiler create a compiler of the native backend
+func NewNativeCompiler() *NativeCompiler {
+	return &NativeCompiler{}
+}
+
+// compileNative compiles targets by the native backend, the syntax and
+// link errors are reported as the compile error
+func compileNative(ctx context.Context, targets []string, runtime *CompilerRuntime) error {
+	if len(runtime.arguments) != 0 {
+		return fmt.Errorf("native: unsupported arguments: %s", strings.Join(runtime.arguments, " "))
+	}
+
+	result, err := native.Compile(runtime.Includes(targets), targets)
+	if err != nil {
+		if _, ok := err.(native.Errors); ok {
+			return NewCompileError(err.Error())
+		}
+		return err
+	}
+
+	for _, plugin := range runtime.Plugins() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := generate(ctx, result, plugin, runtime.stagingDir(runtime.PluginOutputDir(plugin, targets)), runtime); err != nil {
+			return err
+		}
+	}
+
+	if runtime.descriptorSet != "" {
+		content, err := proto.Marshal(result.DescriptorSet(runtime.includeImports, runtime.includeSourceInfo))
+		if err != nil {
+			return err
+		}
+		return fs.WriteFile(runtime.descriptorSet, bytes.NewReader(content), fs.RegularFilePerm)
+	}
+	return nil
+}
+
+// generate runs the plugin over the result and writes the generated
+// files into output directory
+func generate(ctx context.Context, result *native.Result, plugin *Plugin, output string, runtime *CompilerRuntime) error {
+	if plugin.Builtin {
+		return NewCompileError(fmt.Sprintf("--%s_out: built-in generators are unsupported by native backend", plugin.Name))
+	}
+
+	executable, err := runtime.PluginPath(plugin)
+	if err != nil {
+		return NewCompileError(fmt.Sprintf("--%s_out: %s", plugin.Name, err))
+	}
+
+	response, err := native.RunPlugin(ctx, executable, result.Request(strings.Join(plugin.Parameters, ",")))
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return NewCompileError(fmt.Sprintf("--%s_out: %s", plugin.Name, err))
+	} else if response.Error != nil {
+		return NewCompileError(fmt.Sprintf("--%s_out: %s", plugin.Name, response.GetError()))
+	}
+
+	for _, file := range response.GetFile() {
+		name := path.Clean(file.GetName())
+		switch {
+		case file.GetInsertionPoint() != "":
+			return NewCompileError(fmt.Sprintf("--%s_out: %s: insertion points are unsupported by native backend", plugin.Name, name))
+		case path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../"):
+			return NewCompileError(fmt.Sprintf("--%s_out: %s: file name must be relative", plugin.Name, name))
+		}
+
+		if err := fs.WriteFile(filepath.Join(output, filepath.FromSlash(name)), strings.NewReader(file.GetContent()), fs.RegularFilePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,623 @@
+package native
+
+import (
+	"fmt"
+	"math"
+	"protob/pkg/protobuf/parser"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// maxFieldNumber is the exclusive end of field numbers
+	maxFieldNumber = 1 << 29
+)
+
+// scalarTypes maps the names of scalar types to their field types
+var scalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"double":   descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"float":    descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"int64":    descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"uint64":   descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"int32":    descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"fixed64":  descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	"fixed32":  descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+	"bool":     descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string":   descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bytes":    descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"uint32":   descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"sfixed32": descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	"sfixed64": descriptorpb.FieldDescriptorProto_TYPE_SFIXED64,
+	"sint32":   descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	"sint64":   descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+}
+
+// Paths of the elements in source code info, numbers of the fields in
+// descriptor messages
+const (
+	pathFilePackage   = 2
+	pathFileMessage   = 4
+	pathFileEnum      = 5
+	pathFileService   = 6
+	pathFileExtension = 7
+	pathFileSyntax    = 12
+
+	pathMessageField     = 2
+	pathMessageNested    = 3
+	pathMessageEnum      = 4
+	pathMessageExtRange  = 5
+	pathMessageExtension = 6
+	pathMessageOneof     = 8
+
+	pathEnumValue     = 2
+	pathServiceMethod = 2
+)
+
+// nestFunc adds the nested message built at path into the scope
+type nestFunc func(build func(path []int32) *descriptorpb.DescriptorProto)
+
+// reference represents a type name to resolve in scope
+type reference struct {
+	scope, name string
+	pos         parser.Position
+
+	// apply the resolved full name and kind, returns error message if
+	// the kind is not acceptable
+	apply func(full string, kind symbolKind) string
+}
+
+// fieldDefault represents the default value of field to convert after
+// the type of field resolved
+type fieldDefault struct {
+	field *descriptorpb.FieldDescriptorProto
+	value *parser.Option
+}
+
+// linker builds the descriptor of a file from its syntax tree
+type linker struct {
+	c *compiler
+	f *file
+
+	fd *descriptorpb.FileDescriptorProto
+
+	refs     []*reference
+	defaults []*fieldDefault
+	options  []*pendingOptions
+	info     *descriptorpb.SourceCodeInfo
+
+	errs Errors
+}
+
+// link builds the descriptor of file, resolves the types, interprets the
+// standard options, validates and then interprets the custom options
+func (c *compiler) link(name string, f *file) error {
+	l := &linker{c: c, f: f, info: &descriptorpb.SourceCodeInfo{}}
+	l.file(name)
+	if len(l.errs) != 0 {
+		return l.errs
+	}
+
+	f.symbols = symbolsOf(l.fd)
+	l.resolve()
+	l.convertDefaults()
+	if len(l.errs) != 0 {
+		return l.errs
+	}
+
+	// the validation depends on standard options such as allow_alias
+	l.interpretOptions(false)
+	if len(l.errs) != 0 {
+		return l.errs
+	}
+
+	fd, err := protodesc.NewFile(withoutMessageSets(l.fd), c.registry)
+	if err != nil {
+		return Errors{fmt.Errorf("%s: %s", f.path, err)}
+	}
+	if err := c.registry.RegisterFile(fd); err != nil {
+		return Errors{fmt.Errorf("%s: %s", f.path, err)}
+	}
+
+	l.interpretOptions(true)
+	if len(l.errs) != 0 {
+		return l.errs
+	}
+
+	l.fd.SourceCodeInfo = l.info
+	f.descriptor = l.fd
+	return nil
+}
+
+// withoutMessageSets returns the descriptor to validate, message sets are
+// accepted by protoc but rejected by protodesc unless built with the
+// protolegacy tag, so message_set_wire_format is cleared from a copy
+func withoutMessageSets(fd *descriptorpb.FileDescriptorProto) *descriptorpb.FileDescriptorProto {
+	var messageSets []*descriptorpb.MessageOptions
+	var walk func(messages []*descriptorpb.DescriptorProto)
+	walk = func(messages []*descriptorpb.DescriptorProto) {
+		for _, md := range messages {
+			if md.GetOptions().GetMessageSetWireFormat() {
+				messageSets = append(messageSets, md.GetOptions())
+			}
+			walk(md.GetNestedType())
+		}
+	}
+	walk(fd.GetMessageType())
+	if len(messageSets) == 0 {
+		return fd
+	}
+
+	for _, options := range messageSets {
+		options.MessageSetWireFormat = nil
+	}
+	cloned := proto.Clone(fd).(*descriptorpb.FileDescriptorProto)
+	for _, options := range messageSets {
+		options.MessageSetWireFormat = proto.Bool(true)
+	}
+	return cloned
+}
+
+// errorf records an error at the position
+func (l *linker) errorf(pos parser.Position, format string, args ...interface{}) {
+	l.errs = append(l.errs, &parser.Error{File: l.f.path, Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// file builds the descriptor of file
+func (l *linker) file(name string) {
+	ast := l.f.ast
+	l.fd = &descriptorpb.FileDescriptorProto{Name: proto.String(name)}
+	l.locate(nil, &ast.Element)
+
+	var scope string
+	for i, imp := range ast.Imports() {
+		l.fd.Dependency = append(l.fd.Dependency, imp.Path)
+		switch imp.Modifier {
+		case "public":
+			l.fd.PublicDependency = append(l.fd.PublicDependency, int32(i))
+		case "weak":
+			l.fd.WeakDependency = append(l.fd.WeakDependency, int32(i))
+		}
+	}
+
+	for _, decl := range ast.Decls {
+		switch d := decl.(type) {
+		case *parser.Syntax:
+			if d.Value == "proto3" {
+				l.fd.Syntax = proto.String(d.Value)
+			}
+			l.locate([]int32{pathFileSyntax}, &d.Element)
+		case *parser.Package:
+			scope = d.Name
+			l.fd.Package = proto.String(d.Name)
+			l.locate([]int32{pathFilePackage}, &d.Element)
+		}
+	}
+
+	nested := func(build func(path []int32) *descriptorpb.DescriptorProto) {
+		index := len(l.fd.MessageType)
+		l.fd.MessageType = append(l.fd.MessageType, nil)
+		l.fd.MessageType[index] = build([]int32{pathFileMessage, int32(index)})
+	}
+	for _, decl := range ast.Decls {
+		switch d := decl.(type) {
+		case *parser.Message:
+			nested(func(path []int32) *descriptorpb.DescriptorProto {
+				return l.message(d, scope, path)
+			})
+		case *parser.Enum:
+			path := []int32{pathFileEnum, int32(len(l.fd.EnumType))}
+			l.fd.EnumType = append(l.fd.EnumType, l.enum(d, scope, path))
+		case *parser.Service:
+			path := []int32{pathFileService, int32(len(l.fd.Service))}
+			l.fd.Service = append(l.fd.Service, l.service(d, scope, path))
+		case *parser.Extend:
+			for _, field := range d.Fields() {
+				path := []int32{pathFileExtension, int32(len(l.fd.Extension))}
+				l.fd.Extension = append(l.fd.Extension, l.extension(d, field, scope, path, nested))
+			}
+		}
+	}
+
+	if options := l.optionsOf(ast.Options(), scope, &descriptorpb.FileOptions{}); options != nil {
+		l.fd.Options = options.(*descriptorpb.FileOptions)
+	}
+}
+
+// message builds the descriptor of message in scope
+func (l *linker) message(m *parser.Message, scope string, path []int32) *descriptorpb.DescriptorProto {
+	full := join(scope, m.Name)
+	md := &descriptorpb.DescriptorProto{Name: proto.String(m.Name)}
+	l.locate(path, &m.Element)
+
+	nested := func(build func(path []int32) *descriptorpb.DescriptorProto) {
+		index := len(md.NestedType)
+		md.NestedType = append(md.NestedType, nil)
+		md.NestedType[index] = build(append(sub(path, pathMessageNested), int32(index)))
+	}
+
+	var synthetic []*descriptorpb.OneofDescriptorProto
+	addField := func(field *parser.Field, oneof int32) {
+		fieldPath := append(sub(path, pathMessageField), int32(len(md.Field)))
+		if field.Label == "" && oneof < 0 && !field.IsMap() && l.fd.GetSyntax() != "proto3" {
+			l.errorf(field.Pos, "expected \"required\", \"optional\", or \"repeated\"")
+		}
+
+		fd := l.field(field, full, fieldPath, nested)
+		if oneof >= 0 {
+			fd.OneofIndex = proto.Int32(oneof)
+		} else if fd.GetProto3Optional() {
+			// proto3 optional fields are placed in synthetic oneofs after the real ones
+			synthetic = append(synthetic, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + field.Name)})
+			fd.OneofIndex = proto.Int32(int32(-len(synthetic)))
+		}
+		md.Field = append(md.Field, fd)
+	}
+
+	for _, decl := range m.Decls {
+		switch d := decl.(type) {
+		case *parser.Field:
+			addField(d, -1)
+		case *parser.Oneof:
+			index := int32(len(md.OneofDecl))
+			od := &descriptorpb.OneofDescriptorProto{Name: proto.String(d.Name)}
+			if options := l.optionsOf(optionsIn(d.Decls), full, &descriptorpb.OneofOptions{}); options != nil {
+				od.Options = options.(*descriptorpb.OneofOptions)
+			}
+			md.OneofDecl = append(md.OneofDecl, od)
+			l.locate(append(sub(path, pathMessageOneof), index), &d.Element)
+
+			for _, field := range d.Fields() {
+				if field.Label != "" {
+					l.errorf(field.Pos, "fields in oneofs must not have labels")
+				}
+				addField(field, index)
+			}
+		case *parser.Message:
+			nested(func(path []int32) *descriptorpb.DescriptorProto {
+				return l.message(d, full, path)
+			})
+		case *parser.Enum:
+			enumPath := append(sub(path, pathMessageEnum), int32(len(md.EnumType)))
+			md.EnumType = append(md.EnumType, l.enum(d, full, enumPath))
+		case *parser.Extend:
+			for _, field := range d.Fields() {
+				extPath := append(sub(path, pathMessageExtension), int32(len(md.Extension)))
+				md.Extension = append(md.Extension, l.extension(d, field, full, extPath, nested))
+			}
+		case *parser.Extensions:
+			var options *descriptorpb.ExtensionRangeOptions
+			if opts := l.optionsOf(d.Options, full, &descriptorpb.ExtensionRangeOptions{}); opts != nil {
+				options = opts.(*descriptorpb.ExtensionRangeOptions)
+			}
+			for _, r := range d.Ranges {
+				l.locate(append(sub(path, pathMessageExtRange), int32(len(md.ExtensionRange))), &d.Element)
+				start, end := exclusiveRange(r)
+				md.ExtensionRange = append(md.ExtensionRange, &descriptorpb.DescriptorProto_ExtensionRange{
+					Start: proto.Int32(start), End: proto.Int32(end), Options: options,
+				})
+			}
+		case *parser.Reserved:
+			for _, r := range d.Ranges {
+				start, end := exclusiveRange(r)
+				md.ReservedRange = append(md.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+					Start: proto.Int32(start), End: proto.Int32(end),
+				})
+			}
+			md.ReservedName = append(md.ReservedName, d.Names...)
+		}
+	}
+
+	for _, field := range md.Field {
+		if field.OneofIndex != nil && field.GetOneofIndex() < 0 {
+			field.OneofIndex = proto.Int32(int32(len(md.OneofDecl)) - field.GetOneofIndex() - 1)
+		}
+	}
+	md.OneofDecl = append(md.OneofDecl, synthetic...)
+
+	if options := l.optionsOf(m.Options(), full, &descriptorpb.MessageOptions{}); options != nil {
+		md.Options = options.(*descriptorpb.MessageOptions)
+	}
+	return md
+}
+
+// field builds the descriptor of field in scope, the nested adds types
+// of groups and map entries into the scope and returns the path
+func (l *linker) field(f *parser.Field, scope string, path []int32, nested nestFunc) *descriptorpb.FieldDescriptorProto {
+	fd := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(f.Name),
+		Number: proto.Int32(int32(f.Number)),
+	}
+	l.locate(path, &f.Element)
+
+	proto3 := l.fd.GetSyntax() == "proto3"
+	switch f.Label {
+	case "repeated":
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	case "required":
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED.Enum()
+	case "optional":
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+		if proto3 {
+			fd.Proto3Optional = proto.Bool(true)
+		}
+	default:
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	}
+
+	switch {
+	case f.IsMap():
+		if f.Label != "" {
+			l.errorf(f.Pos, "map fields must not have labels")
+		}
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		fd.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+
+		entry := l.mapEntry(f, scope)
+		nested(func([]int32) *descriptorpb.DescriptorProto { return entry })
+		fd.TypeName = proto.String("." + join(scope, entry.GetName()))
+	case f.Group != nil:
+		fd.Name = proto.String(strings.ToLower(f.Name))
+		fd.Type = descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum()
+		fd.TypeName = proto.String("." + join(scope, f.Name))
+		nested(func(path []int32) *descriptorpb.DescriptorProto {
+			return l.message(f.Group, scope, path)
+		})
+	default:
+		l.fieldType(fd, f.Type, scope, f.Pos)
+	}
+	fd.JsonName = proto.String(jsonName(fd.GetName()))
+
+	var options []*parser.Option
+	for _, option := range f.Options {
+		switch option.Name {
+		case "default":
+			if proto3 {
+				l.errorf(option.Pos, "explicit default values are not allowed in proto3")
+			}
+			l.defaults = append(l.defaults, &fieldDefault{field: fd, value: option})
+		case "json_name":
+			if option.Value.Kind != parser.ConstantString {
+				l.errorf(option.Pos, "option json_name must be a string")
+			}
+			fd.JsonName = proto.String(option.Value.String)
+		default:
+			options = append(options, option)
+		}
+	}
+	if opts := l.optionsOf(options, scope, &descriptorpb.FieldOptions{}); opts != nil {
+		fd.Options = opts.(*descriptorpb.FieldOptions)
+	}
+	return fd
+}
+
+// extension builds the descriptor of extension field declared in extend
+func (l *linker) extension(e *parser.Extend, f *parser.Field, scope string, path []int32, nested nestFunc) *descriptorpb.FieldDescriptorProto {
+	if f.IsMap() {
+		l.errorf(f.Pos, "map fields are not allowed in extensions")
+	} else if f.Label == "" && l.fd.GetSyntax() != "proto3" {
+		l.errorf(f.Pos, "expected \"required\", \"optional\", or \"repeated\"")
+	}
+
+	fd := l.field(f, scope, path, nested)
+	fd.Proto3Optional = nil
+	fd.Extendee = proto.String(e.Type)
+	l.refs = append(l.refs, &reference{scope: scope, name: e.Type, pos: e.Pos, apply: func(full string, kind symbolKind) string {
+		if kind != symbolMessage {
+			return fmt.Sprintf("\"%s\" is not a message type", e.Type)
+		}
+		fd.Extendee = proto.String("." + full)
+		return ""
+	}})
+	return fd
+}
+
+// mapEntry builds the nested message of map field
+func (l *linker) mapEntry(f *parser.Field, scope string) *descriptorpb.DescriptorProto {
+	name := mapEntryName(f.Name)
+	key := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("key"),
+		Number:   proto.Int32(1),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String("key"),
+	}
+	if typ, ok := scalarTypes[f.KeyType]; !ok || typ == descriptorpb.FieldDescriptorProto_TYPE_FLOAT ||
+		typ == descriptorpb.FieldDescriptorProto_TYPE_DOUBLE || typ == descriptorpb.FieldDescriptorProto_TYPE_BYTES {
+		l.errorf(f.Pos, "key in map fields cannot be %s", f.KeyType)
+	} else {
+		key.Type = typ.Enum()
+	}
+
+	value := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("value"),
+		Number:   proto.Int32(2),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String("value"),
+	}
+	l.fieldType(value, f.ValueType, join(scope, name), f.Pos)
+
+	return &descriptorpb.DescriptorProto{
+		Name:    proto.String(name),
+		Field:   []*descriptorpb.FieldDescriptorProto{key, value},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+}
+
+// fieldType sets type of the field, message and enum types are resolved
+// after all types declared
+func (l *linker) fieldType(fd *descriptorpb.FieldDescriptorProto, name, scope string, pos parser.Position) {
+	if typ, ok := scalarTypes[name]; ok {
+		fd.Type = typ.Enum()
+		return
+	}
+
+	fd.TypeName = proto.String(name)
+	l.refs = append(l.refs, &reference{scope: scope, name: name, pos: pos, apply: func(full string, kind symbolKind) string {
+		switch kind {
+		case symbolMessage:
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		case symbolEnum:
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum()
+		default:
+			return fmt.Sprintf("\"%s\" is not a type", name)
+		}
+		fd.TypeName = proto.String("." + full)
+		return ""
+	}})
+}
+
+// enum builds the descriptor of enum in scope
+func (l *linker) enum(e *parser.Enum, scope string, path []int32) *descriptorpb.EnumDescriptorProto {
+	ed := &descriptorpb.EnumDescriptorProto{Name: proto.String(e.Name)}
+	l.locate(path, &e.Element)
+
+	for _, decl := range e.Decls {
+		switch d := decl.(type) {
+		case *parser.EnumValue:
+			l.locate(append(sub(path, pathEnumValue), int32(len(ed.Value))), &d.Element)
+			vd := &descriptorpb.EnumValueDescriptorProto{Name: proto.String(d.Name), Number: proto.Int32(int32(d.Number))}
+			if options := l.optionsOf(d.Options, scope, &descriptorpb.EnumValueOptions{}); options != nil {
+				vd.Options = options.(*descriptorpb.EnumValueOptions)
+			}
+			ed.Value = append(ed.Value, vd)
+		case *parser.Reserved:
+			for _, r := range d.Ranges {
+				end := int32(r.End)
+				if r.Max {
+					end = math.MaxInt32
+				}
+				ed.ReservedRange = append(ed.ReservedRange, &descriptorpb.EnumDescriptorProto_EnumReservedRange{
+					Start: proto.Int32(int32(r.Start)), End: proto.Int32(end),
+				})
+			}
+			ed.ReservedName = append(ed.ReservedName, d.Names...)
+		}
+	}
+
+	if options := l.optionsOf(e.Options(), scope, &descriptorpb.EnumOptions{}); options != nil {
+		ed.Options = options.(*descriptorpb.EnumOptions)
+	}
+	return ed
+}
+
+// service builds the descriptor of service in scope
+func (l *linker) service(s *parser.Service, scope string, path []int32) *descriptorpb.ServiceDescriptorProto {
+	full := join(scope, s.Name)
+	sd := &descriptorpb.ServiceDescriptorProto{Name: proto.String(s.Name)}
+	l.locate(path, &s.Element)
+
+	for i, rpc := range s.RPCs() {
+		l.locate(append(sub(path, pathServiceMethod), int32(i)), &rpc.Element)
+		md := &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(rpc.Name),
+			InputType:  proto.String(rpc.InputType),
+			OutputType: proto.String(rpc.OutputType),
+		}
+		if rpc.InputStream {
+			md.ClientStreaming = proto.Bool(true)
+		}
+		if rpc.OutputStream {
+			md.ServerStreaming = proto.Bool(true)
+		}
+		l.methodType(&md.InputType, rpc.InputType, full, rpc.Pos)
+		l.methodType(&md.OutputType, rpc.OutputType, full, rpc.Pos)
+
+		if options := l.optionsOf(rpc.Options(), full, &descriptorpb.MethodOptions{}); options != nil {
+			md.Options = options.(*descriptorpb.MethodOptions)
+		}
+		sd.Method = append(sd.Method, md)
+	}
+
+	if options := l.optionsOf(s.Options(), full, &descriptorpb.ServiceOptions{}); options != nil {
+		sd.Options = options.(*descriptorpb.ServiceOptions)
+	}
+	return sd
+}
+
+// methodType resolves the request or response type of method
+func (l *linker) methodType(target **string, name, scope string, pos parser.Position) {
+	l.refs = append(l.refs, &reference{scope: scope, name: name, pos: pos, apply: func(full string, kind symbolKind) string {
+		if kind != symbolMessage {
+			return fmt.Sprintf("\"%s\" is not a message type", name)
+		}
+		*target = proto.String("." + full)
+		return ""
+	}})
+}
+
+// resolve resolves all of the type references
+func (l *linker) resolve() {
+	visible := l.c.visible(l.f)
+	for _, ref := range l.refs {
+		full, kind, ok := lookupSymbol(visible, ref.scope, ref.name, isType)
+		if !ok {
+			l.errorf(ref.pos, "\"%s\" is not defined", ref.name)
+			continue
+		}
+		if message := ref.apply(full, kind); message != "" {
+			l.errorf(ref.pos, "%s", message)
+		}
+	}
+}
+
+// exclusiveRange returns the range of field numbers with exclusive end
+func exclusiveRange(r parser.Range) (int32, int32) {
+	if r.Max {
+		return int32(r.Start), maxFieldNumber
+	}
+	return int32(r.Start), int32(r.End + 1)
+}
+
+// optionsIn returns the option statements in declarations
+func optionsIn(decls []parser.Decl) []*parser.Option {
+	var options []*parser.Option
+	for _, decl := range decls {
+		if option, ok := decl.(*parser.Option); ok {
+			options = append(options, option)
+		}
+	}
+	return options
+}
+
+// join returns the full name of name in scope
+func join(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// sub returns a copy of path with elements appended
+func sub(path []int32, elems ...int32) []int32 {
+	return append(append(make([]int32, 0, len(path)+len(elems)+1), path...), elems...)
+}
+
+// jsonName returns the json name of field in lower camel case
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper && 'a' <= r && r <= 'z':
+			b.WriteRune(r - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(r)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+// mapEntryName returns the name of nested message of map field
+func mapEntryName(name string) string {
+	camel := jsonName(name)
+	if camel != "" && 'a' <= camel[0] && camel[0] <= 'z' {
+		camel = string(camel[0]-'a'+'A') + camel[1:]
+	}
+	return camel + "Entry"
+}
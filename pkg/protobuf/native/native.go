@@ -0,0 +1,239 @@
+package native
+
+import (
+	"fmt"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf/parser"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	// well-known types used when not found in the include paths
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/apipb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/sourcecontextpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/typepb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+	_ "google.golang.org/protobuf/types/pluginpb"
+)
+
+// Errors represents the errors reported while compiling, the errors with
+// location are in form of file:line:column: message
+type Errors []error
+
+// Error returns the errors line by line
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Result represents the file descriptors compiled from targets
+type Result struct {
+	// names of the targets relative to the include paths
+	Targets []string
+
+	// descriptors of the targets and all of their imports, the imports
+	// are placed before the files importing them
+	Files []*descriptorpb.FileDescriptorProto
+}
+
+// Descriptor returns the descriptor of file by name or nil
+func (r *Result) Descriptor(name string) *descriptorpb.FileDescriptorProto {
+	for _, file := range r.Files {
+		if file.GetName() == name {
+			return file
+		}
+	}
+	return nil
+}
+
+// Compile parses and links targets with their imports into descriptors,
+// the imports are looked up from includes in order, and then from the
+// well-known types built in protobuf runtime
+func Compile(includes, targets []string) (*Result, error) {
+	c := &compiler{
+		includes: includes,
+		files:    make(map[string]*file),
+		registry: new(protoregistry.Files),
+	}
+
+	result := &Result{}
+	for _, target := range targets {
+		name, err := c.targetName(target)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := c.load(name, target, nil); err != nil {
+			return nil, err
+		}
+		result.Targets = append(result.Targets, name)
+	}
+
+	for _, f := range c.order {
+		result.Files = append(result.Files, f.descriptor)
+	}
+	return result, nil
+}
+
+// file represents a loaded file with its descriptor
+type file struct {
+	// path of the source, empty if built in protobuf runtime
+	path string
+
+	// syntax tree of the source, nil if built in protobuf runtime
+	ast *parser.File
+
+	// descriptor linked from the syntax tree
+	descriptor *descriptorpb.FileDescriptorProto
+
+	// symbols declared in the file and their kinds
+	symbols map[string]symbolKind
+
+	// imported files
+	imports []*file
+
+	// whether all imports are loaded
+	loaded bool
+}
+
+// compiler loads and links files in order of imports
+type compiler struct {
+	// include paths to lookup imports
+	includes []string
+
+	// loaded files by name
+	files map[string]*file
+
+	// linked files, the imports are placed before the files importing them
+	order []*file
+
+	// descriptors of linked files to resolve options
+	registry *protoregistry.Files
+
+	// dynamic types of extensions used by custom options
+	extensions map[protoreflect.FullName]protoreflect.ExtensionType
+}
+
+// targetName returns name of the target relative to the first include
+// path containing it
+func (c *compiler) targetName(target string) (string, error) {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+
+	for _, include := range c.includes {
+		dir, err := filepath.Abs(include)
+		if err != nil {
+			continue
+		}
+
+		if rel, err := filepath.Rel(dir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel), nil
+		}
+	}
+	return "", fmt.Errorf("%s: file does not reside within any include path", target)
+}
+
+// lookup returns path of the file by name in include paths
+func (c *compiler) lookup(name string) string {
+	for _, include := range c.includes {
+		path := fs.Join(include, name)
+		if ok, _ := fs.IsFile(path); ok {
+			return path
+		}
+	}
+	return ""
+}
+
+// load parses the file and its imports and links them, the path is
+// looked up from include paths if empty, the import is the statement
+// importing the file for error reporting
+func (c *compiler) load(name, path string, from *importRef) (*file, error) {
+	if f, ok := c.files[name]; ok {
+		if !f.loaded {
+			return nil, from.errorf("import cycle with \"%s\"", name)
+		}
+		return f, nil
+	}
+
+	if path == "" {
+		if path = c.lookup(name); path == "" {
+			if fd, err := protoregistry.GlobalFiles.FindFileByPath(name); err == nil {
+				return c.builtin(name, fd)
+			}
+			return nil, from.errorf("import \"%s\" was not found", name)
+		}
+	}
+
+	ast, err := parser.ParseFile(path)
+	if err != nil {
+		return nil, Errors{err}
+	}
+
+	f := &file{path: path, ast: ast}
+	c.files[name] = f
+	for _, imp := range ast.Imports() {
+		dep, err := c.load(imp.Path, "", &importRef{path: path, imp: imp})
+		if err != nil {
+			return nil, err
+		}
+		f.imports = append(f.imports, dep)
+	}
+	f.loaded = true
+
+	if err := c.link(name, f); err != nil {
+		return nil, err
+	}
+	c.order = append(c.order, f)
+	return f, nil
+}
+
+// builtin loads the file built in protobuf runtime
+func (c *compiler) builtin(name string, fd protoreflect.FileDescriptor) (*file, error) {
+	f := &file{descriptor: protodesc.ToFileDescriptorProto(fd), loaded: true}
+	c.files[name] = f
+
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		dep, err := c.load(imports.Get(i).Path(), "", nil)
+		if err != nil {
+			return nil, err
+		}
+		f.imports = append(f.imports, dep)
+	}
+
+	f.symbols = symbolsOf(f.descriptor)
+	if err := c.registry.RegisterFile(fd); err != nil {
+		return nil, Errors{fmt.Errorf("%s: %s", name, err)}
+	}
+	c.order = append(c.order, f)
+	return f, nil
+}
+
+// importRef represents an import statement in file
+type importRef struct {
+	path string
+	imp  *parser.Import
+}
+
+// errorf returns an error at the import statement
+func (r *importRef) errorf(format string, args ...interface{}) error {
+	if r == nil {
+		return Errors{fmt.Errorf(format, args...)}
+	}
+	return Errors{&parser.Error{File: r.path, Pos: r.imp.Pos, Message: fmt.Sprintf(format, args...)}}
+}
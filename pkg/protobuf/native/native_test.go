@@ -0,0 +1,317 @@
+package native
+
+import (
+	"path/filepath"
+	"protob/pkg/os/fs/fstest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const annotationsProto = `syntax = "proto3";
+package acme.options;
+
+import "google/protobuf/descriptor.proto";
+
+message Rule {
+  string pattern = 1;
+  repeated string tags = 2;
+}
+
+extend google.protobuf.FieldOptions {
+  bool sensitive = 50001;
+  Rule rule = 50002;
+}
+`
+
+const userProto = `syntax = "proto3";
+package acme.user.v1;
+
+import "acme/options/annotations.proto";
+import "google/protobuf/timestamp.proto";
+
+option go_package = "example.com/acme/user/v1;userv1";
+option java_multiple_files = true;
+
+// User is a registered account.
+message User {
+  // unique identity
+  string id = 1; // immutable
+  string email = 2 [(acme.options.sensitive) = true, (acme.options.rule) = { pattern: ".+@.+" tags: "email" }];
+  optional string nickname = 3 [json_name = "nick"];
+  map<string, Address> addresses = 4;
+  google.protobuf.Timestamp created_at = 5 [deprecated = true];
+  Status status = 6;
+
+  oneof contact {
+    string phone = 7;
+    Address mail = 8;
+  }
+
+  message Address {
+    string city = 1;
+  }
+
+  enum Status {
+    STATUS_UNSPECIFIED = 0;
+    STATUS_ACTIVE = 1;
+  }
+
+  reserved 10 to 12, 20 to max;
+  reserved "legacy";
+}
+
+service UserService {
+  rpc GetUser(User) returns (stream .acme.user.v1.User);
+}
+`
+
+func TestCompile(t *testing.T) {
+	root := fstest.TempDir(t, map[string]string{
+		"acme/options/annotations.proto": annotationsProto,
+		"acme/user/v1/user.proto":        userProto,
+	})
+
+	result, err := Compile([]string{root}, []string{filepath.Join(root, "acme/user/v1/user.proto")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"acme/user/v1/user.proto"}; !reflect.DeepEqual(result.Targets, expected) {
+		t.Errorf("Targets = %v, expected %v", result.Targets, expected)
+	}
+
+	var names []string
+	for _, file := range result.Files {
+		names = append(names, file.GetName())
+	}
+	expectedNames := []string{
+		"google/protobuf/descriptor.proto", "acme/options/annotations.proto",
+		"google/protobuf/timestamp.proto", "acme/user/v1/user.proto",
+	}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Files = %v, expected %v", names, expectedNames)
+	}
+
+	if _, err := protodesc.NewFiles(result.DescriptorSet(true, true)); err != nil {
+		t.Fatalf("descriptor set invalid: %s", err)
+	}
+
+	file := result.Descriptor("acme/user/v1/user.proto")
+	if got := file.GetOptions().GetGoPackage(); got != "example.com/acme/user/v1;userv1" {
+		t.Errorf("go_package = %q", got)
+	}
+
+	user := file.GetMessageType()[0]
+	fields := make(map[string]*descriptorpb.FieldDescriptorProto)
+	for _, field := range user.GetField() {
+		fields[field.GetName()] = field
+	}
+
+	if got := fields["created_at"].GetTypeName(); got != ".google.protobuf.Timestamp" {
+		t.Errorf("created_at type = %s", got)
+	}
+	if !fields["created_at"].GetOptions().GetDeprecated() {
+		t.Errorf("created_at not deprecated")
+	}
+	if got := fields["status"]; got.GetType() != descriptorpb.FieldDescriptorProto_TYPE_ENUM || got.GetTypeName() != ".acme.user.v1.User.Status" {
+		t.Errorf("status type = %s %s", got.GetType(), got.GetTypeName())
+	}
+	if got := fields["addresses"]; got.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED || got.GetTypeName() != ".acme.user.v1.User.AddressesEntry" {
+		t.Errorf("addresses type = %s %s", got.GetLabel(), got.GetTypeName())
+	}
+	if got := fields["nickname"]; !got.GetProto3Optional() || got.GetJsonName() != "nick" || got.GetOneofIndex() != 1 {
+		t.Errorf("nickname = %v", got)
+	}
+	if got := fields["mail"]; got.GetOneofIndex() != 0 || got.GetTypeName() != ".acme.user.v1.User.Address" {
+		t.Errorf("mail = %v", got)
+	}
+	if got := fields["created_at"].GetJsonName(); got != "createdAt" {
+		t.Errorf("created_at json_name = %s", got)
+	}
+
+	var oneofs []string
+	for _, oneof := range user.GetOneofDecl() {
+		oneofs = append(oneofs, oneof.GetName())
+	}
+	if expected := []string{"contact", "_nickname"}; !reflect.DeepEqual(oneofs, expected) {
+		t.Errorf("oneofs = %v, expected %v", oneofs, expected)
+	}
+
+	if got := user.GetReservedRange(); len(got) != 2 || got[0].GetEnd() != 13 || got[1].GetEnd() != maxFieldNumber {
+		t.Errorf("reserved ranges = %v", got)
+	}
+
+	method := file.GetService()[0].GetMethod()[0]
+	if method.GetInputType() != ".acme.user.v1.User" || method.GetOutputType() != ".acme.user.v1.User" || !method.GetServerStreaming() {
+		t.Errorf("method = %v", method)
+	}
+
+	// custom options are encoded as extensions of options
+	content, err := proto.Marshal(fields["email"].GetOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed := &descriptorpb.FieldOptions{}
+	if err := proto.Unmarshal(content, reparsed); err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[protowire.Number][]byte)
+	for unknown := reparsed.ProtoReflect().GetUnknown(); len(unknown) != 0; {
+		number, _, n := protowire.ConsumeField(unknown)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		values[number] = unknown[:n]
+		unknown = unknown[n:]
+	}
+	if got := values[50001]; !reflect.DeepEqual(got, protowire.AppendVarint(protowire.AppendTag(nil, 50001, protowire.VarintType), 1)) {
+		t.Errorf("(acme.options.sensitive) = %v", got)
+	}
+	if got := string(values[50002]); !strings.Contains(got, ".+@.+") || !strings.Contains(got, "email") {
+		t.Errorf("(acme.options.rule) = %q", got)
+	}
+
+	var comments []string
+	for _, location := range file.GetSourceCodeInfo().GetLocation() {
+		if location.LeadingComments != nil || location.TrailingComments != nil {
+			comments = append(comments, location.GetLeadingComments()+"|"+location.GetTrailingComments())
+		}
+	}
+	if expected := []string{" User is a registered account.\n|", " unique identity\n| immutable\n"}; !reflect.DeepEqual(comments, expected) {
+		t.Errorf("comments = %q, expected %q", comments, expected)
+	}
+}
+
+func TestCompileProto2(t *testing.T) {
+	root := fstest.TempDir(t, map[string]string{
+		"legacy.proto": `
+package legacy;
+
+message Search {
+  optional string query = 1 [default = "*"];
+  optional int32 limit = 2 [default = -10];
+  optional bytes magic = 3 [default = "\001a"];
+  optional Kind kind = 4 [default = KIND_B];
+  repeated group Result = 5 {
+    required string url = 6;
+  }
+  extensions 100 to max;
+
+  enum Kind {
+    KIND_A = 1;
+    KIND_B = 2;
+  }
+}
+
+extend Search {
+  optional int64 rank = 100;
+}
+`,
+	})
+
+	result, err := Compile([]string{root}, []string{filepath.Join(root, "legacy.proto")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := result.Descriptor("legacy.proto")
+	search := file.GetMessageType()[0]
+	var defaults []string
+	for _, field := range search.GetField() {
+		defaults = append(defaults, field.GetDefaultValue())
+	}
+	if expected := []string{"*", "-10", `\001a`, "KIND_B", ""}; !reflect.DeepEqual(defaults, expected) {
+		t.Errorf("defaults = %q, expected %q", defaults, expected)
+	}
+
+	result5 := search.GetField()[4]
+	if result5.GetName() != "result" || result5.GetType() != descriptorpb.FieldDescriptorProto_TYPE_GROUP || result5.GetTypeName() != ".legacy.Search.Result" {
+		t.Errorf("group = %v", result5)
+	}
+	if got := file.GetExtension()[0].GetExtendee(); got != ".legacy.Search" {
+		t.Errorf("extendee = %s", got)
+	}
+	if file.Syntax != nil {
+		t.Errorf("syntax = %s, expected unset", file.GetSyntax())
+	}
+}
+
+func TestCompileStandardOptions(t *testing.T) {
+	root := fstest.TempDir(t, map[string]string{
+		"options.proto": `
+package options;
+
+enum Code {
+  option allow_alias = true;
+  CODE_OK = 0;
+  CODE_SUCCESS = 0;
+}
+
+message Sample {
+  repeated int32 values = 1 [packed = true];
+}
+
+message Bag {
+  option message_set_wire_format = true;
+  extensions 4 to max;
+}
+`,
+	})
+
+	result, err := Compile([]string{root}, []string{filepath.Join(root, "options.proto")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := result.Descriptor("options.proto")
+	if !file.GetEnumType()[0].GetOptions().GetAllowAlias() {
+		t.Errorf("allow_alias not set")
+	}
+	if !file.GetMessageType()[0].GetField()[0].GetOptions().GetPacked() {
+		t.Errorf("packed not set")
+	}
+	if !file.GetMessageType()[1].GetOptions().GetMessageSetWireFormat() {
+		t.Errorf("message_set_wire_format not set")
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	cases := map[string]string{
+		"undefined.proto": "syntax = \"proto3\";\nmessage A {\n  B b = 1;\n}\n",
+		"missing.proto":   "syntax = \"proto3\";\nimport \"not/exists.proto\";\n",
+		"option.proto":    "syntax = \"proto3\";\noption (unknown) = true;\n",
+		"label.proto":     "message A {\n  string name = 1;\n}\n",
+		"syntax.proto":    "syntax = \"proto3\";\nmessage A {\n",
+		"duplicate.proto": "syntax = \"proto3\";\nmessage A {\n  string a = 1;\n  string b = 1;\n}\n",
+		"alias.proto":     "syntax = \"proto3\";\nenum E {\n  E_A = 0;\n  E_B = 0;\n}\n",
+	}
+	expected := map[string]string{
+		"undefined.proto": "undefined.proto:3:3: \"B\" is not defined",
+		"missing.proto":   "missing.proto:2:1: import \"not/exists.proto\" was not found",
+		"option.proto":    "option.proto:2:1: option \"(unknown)\" unknown",
+		"label.proto":     "label.proto:2:3: expected \"required\", \"optional\", or \"repeated\"",
+		"syntax.proto":    "syntax.proto:",
+		"duplicate.proto": "duplicate.proto: ",
+		"alias.proto":     "alias.proto: ",
+	}
+
+	fstest.Chdir(t, fstest.TempDir(t, cases))
+
+	for name := range cases {
+		_, err := Compile([]string{"."}, []string{name})
+		if _, ok := err.(Errors); !ok {
+			t.Errorf("Compile(%s) = %v, expected Errors", name, err)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), expected[name]) {
+			t.Errorf("Compile(%s) = %q, expected %q", name, err, expected[name])
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package native
+
+import (
+	"fmt"
+	"math"
+	"protob/pkg/protobuf/parser"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// pendingOptions represents the options to interpret after the file is
+// linked, the custom options may be declared in the file itself
+type pendingOptions struct {
+	scope   string
+	options []*parser.Option
+	message proto.Message
+}
+
+// optionsOf returns the options message which the options interpreted
+// into later, nil if no options
+func (l *linker) optionsOf(options []*parser.Option, scope string, message proto.Message) proto.Message {
+	if len(options) == 0 {
+		return nil
+	}
+
+	l.options = append(l.options, &pendingOptions{scope: scope, options: options, message: message})
+	return message
+}
+
+// interpretOptions sets the standard or custom options into options
+// messages, standard options are fields of options messages and custom
+// options are extensions declared in the visible files, which are only
+// resolvable after the file is registered
+func (l *linker) interpretOptions(custom bool) {
+	var visible map[string]symbolKind
+	if custom {
+		visible = l.c.visible(l.f)
+	}
+	for _, pending := range l.options {
+		for _, option := range pending.options {
+			if isCustom(option) != custom {
+				continue
+			}
+			if err := l.setOption(visible, pending.message.ProtoReflect(), pending.scope, option); err != nil {
+				l.errorf(option.Pos, "%s", err)
+			}
+		}
+	}
+}
+
+// isCustom reports whether the option is set through an extension
+func isCustom(option *parser.Option) bool {
+	for _, part := range option.Parts {
+		if part.Extension {
+			return true
+		}
+	}
+	return false
+}
+
+// setOption sets the option into options message
+func (l *linker) setOption(visible map[string]symbolKind, message protoreflect.Message, scope string, option *parser.Option) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("option %s: %v", option.Name, r)
+		}
+	}()
+
+	for i, part := range option.Parts {
+		var fd protoreflect.FieldDescriptor
+		if part.Extension {
+			full, _, ok := lookupSymbol(visible, scope, part.Name, isExtension)
+			if !ok {
+				return fmt.Errorf("option \"(%s)\" unknown", part.Name)
+			}
+
+			d, err := l.c.registry.FindDescriptorByName(protoreflect.FullName(full))
+			if err != nil {
+				return fmt.Errorf("option \"(%s)\": %s", part.Name, err)
+			}
+			xd := d.(protoreflect.ExtensionDescriptor)
+			if got, want := xd.ContainingMessage().FullName(), message.Descriptor().FullName(); got != want {
+				return fmt.Errorf("option \"(%s)\" extends %s, not %s", part.Name, got, want)
+			}
+			fd = l.c.extensionType(xd).TypeDescriptor()
+		} else if fd = message.Descriptor().Fields().ByName(protoreflect.Name(part.Name)); fd == nil {
+			return fmt.Errorf("option \"%s\" unknown for %s", part.Name, message.Descriptor().FullName())
+		}
+
+		if i < len(option.Parts)-1 {
+			if fd.Message() == nil || fd.IsList() {
+				return fmt.Errorf("option \"%s\" is not a message", option.Name)
+			}
+			message = message.Mutable(fd).Message()
+			continue
+		}
+
+		value, err := optionValue(message, fd, option.Value)
+		if err != nil {
+			return fmt.Errorf("option %s: %s", option.Name, err)
+		}
+
+		switch {
+		case fd.IsList():
+			message.Mutable(fd).List().Append(value)
+		case message.Has(fd) && fd.Message() == nil:
+			return fmt.Errorf("option %s already set", option.Name)
+		default:
+			message.Set(fd, value)
+		}
+	}
+	return nil
+}
+
+// extensionType returns the dynamic type of extension
+func (c *compiler) extensionType(xd protoreflect.ExtensionDescriptor) protoreflect.ExtensionType {
+	if c.extensions == nil {
+		c.extensions = make(map[protoreflect.FullName]protoreflect.ExtensionType)
+	}
+	if xt, ok := c.extensions[xd.FullName()]; ok {
+		return xt
+	}
+
+	xt := dynamicpb.NewExtensionType(xd)
+	c.extensions[xd.FullName()] = xt
+	return xt
+}
+
+// optionValue converts the constant into value of field
+func optionValue(message protoreflect.Message, fd protoreflect.FieldDescriptor, c *parser.Constant) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if c.Kind == parser.ConstantIdent && (c.Raw == "true" || c.Raw == "false") {
+			return protoreflect.ValueOfBool(c.Raw == "true"), nil
+		}
+	case protoreflect.EnumKind:
+		if c.Kind == parser.ConstantIdent {
+			if value := fd.Enum().Values().ByName(protoreflect.Name(c.Raw)); value != nil {
+				return protoreflect.ValueOfEnum(value.Number()), nil
+			}
+			return protoreflect.Value{}, fmt.Errorf("enum %s has no value named %s", fd.Enum().FullName(), c.Raw)
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if v, err := parseInt(c, 32); err == nil {
+			return protoreflect.ValueOfInt32(int32(v)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if v, err := parseInt(c, 64); err == nil {
+			return protoreflect.ValueOfInt64(v), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if v, err := parseUint(c, 32); err == nil {
+			return protoreflect.ValueOfUint32(uint32(v)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if v, err := parseUint(c, 64); err == nil {
+			return protoreflect.ValueOfUint64(v), nil
+		}
+	case protoreflect.FloatKind:
+		if v, err := parseFloat(c); err == nil {
+			return protoreflect.ValueOfFloat32(float32(v)), nil
+		}
+	case protoreflect.DoubleKind:
+		if v, err := parseFloat(c); err == nil {
+			return protoreflect.ValueOfFloat64(v), nil
+		}
+	case protoreflect.StringKind:
+		if c.Kind == parser.ConstantString {
+			return protoreflect.ValueOfString(c.String), nil
+		}
+	case protoreflect.BytesKind:
+		if c.Kind == parser.ConstantString {
+			return protoreflect.ValueOfBytes([]byte(c.String)), nil
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if c.Kind == parser.ConstantAggregate {
+			value := message.NewField(fd)
+			text := c.Raw[1 : len(c.Raw)-1]
+			if err := prototext.Unmarshal([]byte(text), value.Message().Interface()); err != nil {
+				return protoreflect.Value{}, err
+			}
+			return value, nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("value %s invalid for %s", c.Raw, fd.Kind())
+}
+
+// convertDefaults converts default values of fields by their types
+func (l *linker) convertDefaults() {
+	for _, d := range l.defaults {
+		value, err := defaultValue(d.field, d.value.Value)
+		if err != nil {
+			l.errorf(d.value.Pos, "%s", err)
+			continue
+		}
+		d.field.DefaultValue = proto.String(value)
+	}
+}
+
+// defaultValue returns the default value of field in form of descriptor
+func defaultValue(fd *descriptorpb.FieldDescriptorProto, c *parser.Constant) (string, error) {
+	if fd.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		return "", fmt.Errorf("repeated fields can't have default values")
+	}
+
+	switch fd.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		if c.Kind == parser.ConstantString {
+			return c.String, nil
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		if c.Kind == parser.ConstantString {
+			return escapeBytes(c.String), nil
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		if c.Kind == parser.ConstantIdent && (c.Raw == "true" || c.Raw == "false") {
+			return c.Raw, nil
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		if c.Kind == parser.ConstantIdent {
+			return c.Raw, nil
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		if v, err := parseFloat(c); err == nil {
+			switch {
+			case math.IsInf(v, 1):
+				return "inf", nil
+			case math.IsInf(v, -1):
+				return "-inf", nil
+			case math.IsNaN(v):
+				return "nan", nil
+			}
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+		if v, err := parseInt(c, 32); err == nil {
+			return strconv.FormatInt(v, 10), nil
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		if v, err := parseInt(c, 64); err == nil {
+			return strconv.FormatInt(v, 10), nil
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT32, descriptorpb.FieldDescriptorProto_TYPE_FIXED32:
+		if v, err := parseUint(c, 32); err == nil {
+			return strconv.FormatUint(v, 10), nil
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_FIXED64:
+		if v, err := parseUint(c, 64); err == nil {
+			return strconv.FormatUint(v, 10), nil
+		}
+	default:
+		return "", fmt.Errorf("messages can't have default values")
+	}
+	return "", fmt.Errorf("default value %s invalid for %s", c.Raw, strings.ToLower(strings.TrimPrefix(fd.GetType().String(), "TYPE_")))
+}
+
+// parseInt parses the integer constant in bits
+func parseInt(c *parser.Constant, bits int) (int64, error) {
+	if c.Kind != parser.ConstantInt {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseInt(strings.TrimPrefix(c.Raw, "+"), 0, bits)
+}
+
+// parseUint parses the unsigned integer constant in bits
+func parseUint(c *parser.Constant, bits int) (uint64, error) {
+	if c.Kind != parser.ConstantInt {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseUint(strings.TrimPrefix(c.Raw, "+"), 0, bits)
+}
+
+// parseFloat parses the number constant or inf and nan
+func parseFloat(c *parser.Constant) (float64, error) {
+	switch c.Kind {
+	case parser.ConstantInt:
+		if v, err := parseInt(c, 64); err == nil {
+			return float64(v), nil
+		}
+		v, err := parseUint(c, 64)
+		return float64(v), err
+	case parser.ConstantFloat:
+		return strconv.ParseFloat(strings.TrimPrefix(c.Raw, "+"), 64)
+	case parser.ConstantIdent:
+		if c.Raw == "inf" || c.Raw == "nan" {
+			return strconv.ParseFloat(c.Raw, 64)
+		}
+	}
+	return 0, strconv.ErrSyntax
+}
+
+// escapeBytes escapes the bytes in C style as the default value of bytes
+func escapeBytes(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '"', c == '\'', c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			_, _ = fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package native
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"protob/pkg/os/process"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Request returns the code generator request of the result for plugin
+// with parameter
+func (r *Result) Request(parameter string) *pluginpb.CodeGeneratorRequest {
+	request := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: append([]string{}, r.Targets...),
+		ProtoFile:      r.Files,
+	}
+	if parameter != "" {
+		request.Parameter = proto.String(parameter)
+	}
+	return request
+}
+
+// DescriptorSet returns the file descriptor set of targets, and all of
+// their imports if includeImports, the source code info is stripped
+// unless includeSourceInfo
+func (r *Result) DescriptorSet(includeImports, includeSourceInfo bool) *descriptorpb.FileDescriptorSet {
+	targets := make(map[string]bool)
+	for _, target := range r.Targets {
+		targets[target] = true
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range r.Files {
+		if !includeImports && !targets[file.GetName()] {
+			continue
+		}
+
+		if !includeSourceInfo {
+			file = proto.Clone(file).(*descriptorpb.FileDescriptorProto)
+			file.SourceCodeInfo = nil
+		}
+		set.File = append(set.File, file)
+	}
+	return set
+}
+
+// RunPlugin runs the plugin executable with request over stdin, and
+// returns the response read from stdout, the plugin is killed if ctx is
+// done and the error of ctx is returned
+func RunPlugin(ctx context.Context, path string, request *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	input, err := proto.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(input), &stdout, &stderr
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if err := process.Run(ctx, cmd); err != nil {
+		if err == ctx.Err() {
+			return nil, err
+		} else if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s: %s", name, message)
+		}
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	response := &pluginpb.CodeGeneratorResponse{}
+	if err := proto.Unmarshal(stdout.Bytes(), response); err != nil {
+		return nil, fmt.Errorf("%s: invalid response: %s", name, err)
+	}
+	return response, nil
+}
@@ -0,0 +1,68 @@
+package native
+
+import (
+	"protob/pkg/protobuf/parser"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// locate adds the location of element at path with its comments into
+// source code info
+func (l *linker) locate(path []int32, element *parser.Element) {
+	location := &descriptorpb.SourceCodeInfo_Location{Path: sub(path), Span: span(element)}
+
+	attached := element.Comments.Attached(element.Pos.Line)
+	if len(attached) != 0 {
+		location.LeadingComments = proto.String(commentText(attached))
+	}
+	if trailing := element.Comments.Trailing; trailing != nil {
+		location.TrailingComments = proto.String(commentText([]*parser.Comment{trailing}))
+	}
+
+	// the detached comments are grouped by blank lines between them
+	detached := element.Comments.Leading[:len(element.Comments.Leading)-len(attached)]
+	for start := 0; start < len(detached); {
+		end := start + 1
+		for end < len(detached) && detached[end].Pos.Line <= detached[end-1].End.Line+1 {
+			end++
+		}
+		location.LeadingDetachedComments = append(location.LeadingDetachedComments, commentText(detached[start:end]))
+		start = end
+	}
+
+	l.info.Location = append(l.info.Location, location)
+}
+
+// span returns the span of element in form of source code info: start
+// line, start column, end line if different and end column, start from 0
+func span(element *parser.Element) []int32 {
+	if element.Pos.Line == element.End.Line {
+		return []int32{int32(element.Pos.Line - 1), int32(element.Pos.Column - 1), int32(element.End.Column)}
+	}
+	return []int32{int32(element.Pos.Line - 1), int32(element.Pos.Column - 1), int32(element.End.Line - 1), int32(element.End.Column)}
+}
+
+// commentText returns the content of comments without comment markers,
+// line comments are ended with newline and the leading asterisks of
+// continuation lines in block comments are removed
+func commentText(comments []*parser.Comment) string {
+	var b strings.Builder
+	for _, comment := range comments {
+		if strings.HasPrefix(comment.Text, "//") {
+			b.WriteString(strings.TrimPrefix(comment.Text, "//") + "\n")
+			continue
+		}
+
+		lines := strings.Split(strings.TrimSuffix(strings.TrimPrefix(comment.Text, "/*"), "*/"), "\n")
+		for i, line := range lines {
+			if i > 0 {
+				line = strings.TrimPrefix(strings.TrimLeft(line, " \t"), "*")
+				b.WriteString("\n")
+			}
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
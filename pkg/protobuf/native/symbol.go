@@ -0,0 +1,139 @@
+package native
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// symbolKind represents the kind of declared symbol
+type symbolKind uint8
+
+const (
+	symbolPackage symbolKind = iota + 1
+	symbolMessage
+	symbolEnum
+	symbolService
+	symbolExtension
+)
+
+// isType reports whether the symbol is a message or enum
+func isType(kind symbolKind) bool {
+	return kind == symbolMessage || kind == symbolEnum
+}
+
+// isExtension reports whether the symbol is an extension
+func isExtension(kind symbolKind) bool {
+	return kind == symbolExtension
+}
+
+// symbolsOf returns the symbols declared in the file by full name
+func symbolsOf(fd *descriptorpb.FileDescriptorProto) map[string]symbolKind {
+	symbols := make(map[string]symbolKind)
+	if pkg := fd.GetPackage(); pkg != "" {
+		parts := strings.Split(pkg, ".")
+		for i := range parts {
+			symbols[strings.Join(parts[:i+1], ".")] = symbolPackage
+		}
+	}
+
+	var addMessage func(scope string, md *descriptorpb.DescriptorProto)
+	addMessage = func(scope string, md *descriptorpb.DescriptorProto) {
+		full := join(scope, md.GetName())
+		symbols[full] = symbolMessage
+		for _, nested := range md.GetNestedType() {
+			addMessage(full, nested)
+		}
+		for _, enum := range md.GetEnumType() {
+			symbols[join(full, enum.GetName())] = symbolEnum
+		}
+		for _, extension := range md.GetExtension() {
+			symbols[join(full, extension.GetName())] = symbolExtension
+		}
+	}
+
+	scope := fd.GetPackage()
+	for _, md := range fd.GetMessageType() {
+		addMessage(scope, md)
+	}
+	for _, enum := range fd.GetEnumType() {
+		symbols[join(scope, enum.GetName())] = symbolEnum
+	}
+	for _, service := range fd.GetService() {
+		symbols[join(scope, service.GetName())] = symbolService
+	}
+	for _, extension := range fd.GetExtension() {
+		symbols[join(scope, extension.GetName())] = symbolExtension
+	}
+	return symbols
+}
+
+// visible returns the symbols visible in file, which are declared in the
+// file, its imports and the public imports of them recursively
+func (c *compiler) visible(f *file) map[string]symbolKind {
+	visible := make(map[string]symbolKind)
+	seen := make(map[*file]bool)
+
+	var add func(f *file)
+	add = func(f *file) {
+		if seen[f] {
+			return
+		}
+		seen[f] = true
+
+		for name, kind := range f.symbols {
+			visible[name] = kind
+		}
+		for _, index := range f.descriptor.GetPublicDependency() {
+			if int(index) < len(f.imports) {
+				add(f.imports[index])
+			}
+		}
+	}
+
+	for name, kind := range f.symbols {
+		visible[name] = kind
+	}
+	for _, dep := range f.imports {
+		add(dep)
+	}
+	return visible
+}
+
+// lookupSymbol resolves the name in scope by the rules of protobuf: the
+// first component of name is searched from the innermost scope outwards,
+// and the rest of name must be found in the scope of first component
+func lookupSymbol(symbols map[string]symbolKind, scope, name string, accept func(symbolKind) bool) (string, symbolKind, bool) {
+	if strings.HasPrefix(name, ".") {
+		kind, ok := symbols[name[1:]]
+		return name[1:], kind, ok && accept(kind)
+	}
+
+	first := name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		first = name[:i]
+	}
+
+	for {
+		if kind, ok := symbols[join(scope, first)]; ok {
+			if first == name {
+				if accept(kind) {
+					return join(scope, name), kind, true
+				}
+			} else if kind != symbolPackage || symbols[join(scope, name)] != 0 {
+				full := join(scope, name)
+				kind, ok := symbols[full]
+				return full, kind, ok && accept(kind)
+			}
+		}
+
+		if scope == "" {
+			return "", 0, false
+		}
+		if i := strings.LastIndexByte(scope, '.'); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
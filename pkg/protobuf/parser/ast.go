@@ -0,0 +1,531 @@
+package parser
+
+// Position represents a location in the source, line and column start from 1
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Comment represents a line or block comment with the comment markers
+type Comment struct {
+	// source text of the comment, e.g. "// foo" or "/* foo */"
+	Text string
+
+	// position of the first and the last character
+	Pos, End Position
+}
+
+// Comments represents the comments around a declaration
+type Comments struct {
+	// comments before the declaration, include the detached ones
+	Leading []*Comment
+
+	// comment after the declaration on the same line, or after the
+	// opening brace of a block declaration
+	Trailing *Comment
+}
+
+// Attached returns the leading comments attached to the declaration at line
+// which are not separated from it by blank lines
+func (c *Comments) Attached(line int) []*Comment {
+	start := len(c.Leading)
+	for i := len(c.Leading) - 1; i >= 0; i-- {
+		if c.Leading[i].End.Line < line-1 {
+			break
+		}
+		start, line = i, c.Leading[i].Pos.Line
+	}
+	return c.Leading[start:]
+}
+
+// Decl represents a declaration in the file or the body of a block
+type Decl interface {
+	// Elem returns the position and comments of the declaration
+	Elem() *Element
+}
+
+// Element represents the common part of every declaration
+type Element struct {
+	// position of the first token and the last token
+	Pos, End Position
+
+	// comments around the declaration
+	Comments Comments
+}
+
+// Elem returns the element itself
+func (e *Element) Elem() *Element {
+	return e
+}
+
+// File represents a protobuf source file
+type File struct {
+	Element
+
+	// name of the file
+	Name string
+
+	// syntax declared by the file, "proto2" if omitted
+	Syntax string
+
+	// declarations in order of the source
+	Decls []Decl
+
+	// comments after the last declaration
+	EndComments []*Comment
+}
+
+// Package returns the package declaration or nil if not declared
+func (f *File) Package() *Package {
+	for _, decl := range f.Decls {
+		if pkg, ok := decl.(*Package); ok {
+			return pkg
+		}
+	}
+	return nil
+}
+
+// Imports returns the import declarations
+func (f *File) Imports() []*Import {
+	var imports []*Import
+	for _, decl := range f.Decls {
+		if imp, ok := decl.(*Import); ok {
+			imports = append(imports, imp)
+		}
+	}
+	return imports
+}
+
+// Options returns the file options
+func (f *File) Options() []*Option {
+	return optionsOf(f.Decls)
+}
+
+// Option returns the file option by name or nil
+func (f *File) Option(name string) *Option {
+	return lookupOption(f.Options(), name)
+}
+
+// Messages returns the top level messages
+func (f *File) Messages() []*Message {
+	return messagesOf(f.Decls)
+}
+
+// Enums returns the top level enums
+func (f *File) Enums() []*Enum {
+	return enumsOf(f.Decls)
+}
+
+// Services returns the services
+func (f *File) Services() []*Service {
+	var services []*Service
+	for _, decl := range f.Decls {
+		if service, ok := decl.(*Service); ok {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// Extends returns the top level extend blocks
+func (f *File) Extends() []*Extend {
+	return extendsOf(f.Decls)
+}
+
+// Syntax represents the syntax statement
+type Syntax struct {
+	Element
+
+	// proto2 or proto3
+	Value string
+}
+
+// Package represents the package statement
+type Package struct {
+	Element
+
+	// full name of the package
+	Name string
+}
+
+// Import represents the import statement
+type Import struct {
+	Element
+
+	// imported file path
+	Path string
+
+	// "public", "weak" or empty
+	Modifier string
+}
+
+// ConstantKind represents the kind of constant values
+type ConstantKind uint8
+
+const (
+	// ConstantIdent represents identifiers, include true, false, inf and nan
+	ConstantIdent ConstantKind = iota
+	// ConstantInt represents integers with optional sign
+	ConstantInt
+	// ConstantFloat represents floats with optional sign
+	ConstantFloat
+	// ConstantString represents string literals
+	ConstantString
+	// ConstantAggregate represents message literals in text format
+	ConstantAggregate
+)
+
+// Constant represents the value of option
+type Constant struct {
+	Kind ConstantKind
+
+	// source text of the constant
+	Raw string
+
+	// decoded value of string literal
+	String string
+}
+
+// OptionNamePart represents a part of the option name
+type OptionNamePart struct {
+	// name of the part, without parentheses for extension
+	Name string
+
+	// whether is an extension name in parentheses
+	Extension bool
+}
+
+// Option represents option statement or compact option of fields
+type Option struct {
+	Element
+
+	// full option name, e.g. "go_package" or "(gogoproto.nullable)"
+	Name string
+
+	// parts of the option name separated by dot
+	Parts []OptionNamePart
+
+	// value of the option
+	Value *Constant
+}
+
+// Message represents the message declaration
+type Message struct {
+	Element
+
+	Name string
+
+	// declarations in the body of the message
+	Decls []Decl
+
+	// comments before the closing brace
+	EndComments []*Comment
+}
+
+// Fields returns the fields of the message, include the fields in oneof
+func (m *Message) Fields() []*Field {
+	var fields []*Field
+	for _, decl := range m.Decls {
+		switch d := decl.(type) {
+		case *Field:
+			fields = append(fields, d)
+		case *Oneof:
+			fields = append(fields, d.Fields()...)
+		}
+	}
+	return fields
+}
+
+// Oneofs returns the oneof declarations
+func (m *Message) Oneofs() []*Oneof {
+	var oneofs []*Oneof
+	for _, decl := range m.Decls {
+		if oneof, ok := decl.(*Oneof); ok {
+			oneofs = append(oneofs, oneof)
+		}
+	}
+	return oneofs
+}
+
+// Messages returns the nested messages
+func (m *Message) Messages() []*Message {
+	return messagesOf(m.Decls)
+}
+
+// Enums returns the nested enums
+func (m *Message) Enums() []*Enum {
+	return enumsOf(m.Decls)
+}
+
+// Extends returns the nested extend blocks
+func (m *Message) Extends() []*Extend {
+	return extendsOf(m.Decls)
+}
+
+// Options returns the message options
+func (m *Message) Options() []*Option {
+	return optionsOf(m.Decls)
+}
+
+// Field represents a field, a map field or a group
+type Field struct {
+	Element
+
+	// "optional", "required", "repeated" or empty
+	Label string
+
+	// type name of the field, "group" for group
+	Type string
+
+	// key and value type of map field
+	KeyType, ValueType string
+
+	Name string
+
+	Number int
+
+	// compact options in brackets
+	Options []*Option
+
+	// body of the group
+	Group *Message
+}
+
+// IsMap reports whether the field is a map field
+func (f *Field) IsMap() bool {
+	return f.KeyType != ""
+}
+
+// Option returns the compact option by name or nil
+func (f *Field) Option(name string) *Option {
+	return lookupOption(f.Options, name)
+}
+
+// Oneof represents the oneof declaration
+type Oneof struct {
+	Element
+
+	Name string
+
+	// declarations in the body of oneof
+	Decls []Decl
+
+	// comments before the closing brace
+	EndComments []*Comment
+}
+
+// Fields returns the fields in the oneof
+func (o *Oneof) Fields() []*Field {
+	var fields []*Field
+	for _, decl := range o.Decls {
+		if field, ok := decl.(*Field); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Enum represents the enum declaration
+type Enum struct {
+	Element
+
+	Name string
+
+	// declarations in the body of the enum
+	Decls []Decl
+
+	// comments before the closing brace
+	EndComments []*Comment
+}
+
+// Values returns the values of the enum
+func (e *Enum) Values() []*EnumValue {
+	var values []*EnumValue
+	for _, decl := range e.Decls {
+		if value, ok := decl.(*EnumValue); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Options returns the enum options
+func (e *Enum) Options() []*Option {
+	return optionsOf(e.Decls)
+}
+
+// EnumValue represents a value of enum
+type EnumValue struct {
+	Element
+
+	Name string
+
+	Number int
+
+	// compact options in brackets
+	Options []*Option
+}
+
+// Range represents a range of field numbers, both ends are inclusive
+type Range struct {
+	Start, End int
+
+	// whether the end is max
+	Max bool
+}
+
+// Reserved represents the reserved statement of numbers or names
+type Reserved struct {
+	Element
+
+	Ranges []Range
+
+	Names []string
+}
+
+// Extensions represents the extensions statement
+type Extensions struct {
+	Element
+
+	Ranges []Range
+
+	// compact options in brackets
+	Options []*Option
+}
+
+// Extend represents the extend declaration
+type Extend struct {
+	Element
+
+	// extended message type
+	Type string
+
+	// declarations in the body of extend
+	Decls []Decl
+
+	// comments before the closing brace
+	EndComments []*Comment
+}
+
+// Fields returns the extension fields
+func (e *Extend) Fields() []*Field {
+	var fields []*Field
+	for _, decl := range e.Decls {
+		if field, ok := decl.(*Field); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Service represents the service declaration
+type Service struct {
+	Element
+
+	Name string
+
+	// declarations in the body of the service
+	Decls []Decl
+
+	// comments before the closing brace
+	EndComments []*Comment
+}
+
+// RPCs returns the methods of the service
+func (s *Service) RPCs() []*RPC {
+	var rpcs []*RPC
+	for _, decl := range s.Decls {
+		if rpc, ok := decl.(*RPC); ok {
+			rpcs = append(rpcs, rpc)
+		}
+	}
+	return rpcs
+}
+
+// Options returns the service options
+func (s *Service) Options() []*Option {
+	return optionsOf(s.Decls)
+}
+
+// RPC represents a method of service
+type RPC struct {
+	Element
+
+	Name string
+
+	InputType  string
+	OutputType string
+
+	InputStream  bool
+	OutputStream bool
+
+	// declarations in the body of the method, nil if declared without body
+	Decls []Decl
+
+	// comments before the closing brace
+	EndComments []*Comment
+}
+
+// Options returns the method options
+func (r *RPC) Options() []*Option {
+	return optionsOf(r.Decls)
+}
+
+// Empty represents the empty statement
+type Empty struct {
+	Element
+}
+
+// optionsOf returns option statements in the declarations
+func optionsOf(decls []Decl) []*Option {
+	var options []*Option
+	for _, decl := range decls {
+		if option, ok := decl.(*Option); ok {
+			options = append(options, option)
+		}
+	}
+	return options
+}
+
+// lookupOption returns the option by name or nil
+func lookupOption(options []*Option, name string) *Option {
+	for _, option := range options {
+		if option.Name == name {
+			return option
+		}
+	}
+	return nil
+}
+
+// messagesOf returns messages in the declarations
+func messagesOf(decls []Decl) []*Message {
+	var messages []*Message
+	for _, decl := range decls {
+		if message, ok := decl.(*Message); ok {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// enumsOf returns enums in the declarations
+func enumsOf(decls []Decl) []*Enum {
+	var enums []*Enum
+	for _, decl := range decls {
+		if enum, ok := decl.(*Enum); ok {
+			enums = append(enums, enum)
+		}
+	}
+	return enums
+}
+
+// extendsOf returns extend blocks in the declarations
+func extendsOf(decls []Decl) []*Extend {
+	var extends []*Extend
+	for _, decl := range decls {
+		if extend, ok := decl.(*Extend); ok {
+			extends = append(extends, extend)
+		}
+	}
+	return extends
+}
@@ -0,0 +1,255 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKind represents the kind of a token
+type tokenKind uint8
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenSymbol
+	tokenComment
+)
+
+// token represents a lexical token in the protobuf source
+type token struct {
+	kind tokenKind
+
+	// source text of the token
+	text string
+
+	// decoded value of the string token
+	value string
+
+	// position of the first and the last character
+	pos, end Position
+
+	// offset of the token in source
+	start, stop int
+}
+
+// lexer splits protobuf source into tokens
+type lexer struct {
+	src  string
+	off  int
+	line int
+	col  int
+}
+
+// lex splits all of the source into tokens, the last one is always EOF
+func lex(src string) ([]*token, error) {
+	l := &lexer{src: src, line: 1, col: 1}
+
+	var tokens []*token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+// position returns the position of current character
+func (l *lexer) position() Position {
+	return Position{Line: l.line, Column: l.col}
+}
+
+// advance moves forward n bytes, keeps track of the line and column
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.off < len(l.src); i++ {
+		if l.src[l.off] == '\n' {
+			l.line, l.col = l.line+1, 1
+		} else if l.src[l.off] < utf8.RuneSelf || utf8.RuneStart(l.src[l.off]) {
+			l.col++
+		}
+		l.off++
+	}
+}
+
+// peek returns the character at offset n from the current, 0 if eof
+func (l *lexer) peek(n int) byte {
+	if l.off+n < len(l.src) {
+		return l.src[l.off+n]
+	}
+	return 0
+}
+
+// next scans the next token
+func (l *lexer) next() (*token, error) {
+	for l.off < len(l.src) && strings.IndexByte(" \t\r\n\f\v", l.src[l.off]) >= 0 {
+		l.advance(1)
+	}
+
+	pos := l.position()
+	start := l.off
+	if l.off >= len(l.src) {
+		return &token{kind: tokenEOF, pos: pos, end: pos, start: l.off, stop: l.off}, nil
+	}
+
+	kind := tokenSymbol
+	value := ""
+	switch c := l.peek(0); {
+	case c == '/' && l.peek(1) == '/':
+		kind = tokenComment
+		for l.off < len(l.src) && l.src[l.off] != '\n' {
+			l.advance(1)
+		}
+	case c == '/' && l.peek(1) == '*':
+		kind = tokenComment
+		end := strings.Index(l.src[l.off+2:], "*/")
+		if end < 0 {
+			return nil, &Error{Pos: pos, Message: "unterminated block comment"}
+		}
+		l.advance(end + 4)
+	case isLetter(c):
+		kind = tokenIdent
+		for l.off < len(l.src) && (isLetter(l.src[l.off]) || isDigit(l.src[l.off])) {
+			l.advance(1)
+		}
+	case isDigit(c) || (c == '.' && isDigit(l.peek(1))):
+		kind = l.scanNumber()
+	case c == '"' || c == '\'':
+		decoded, err := l.scanString()
+		if err != nil {
+			return nil, err
+		}
+		kind, value = tokenString, decoded
+	default:
+		l.advance(1)
+	}
+
+	end := l.position()
+	end.Column--
+	return &token{kind: kind, text: l.src[start:l.off], value: value, pos: pos, end: end, start: start, stop: l.off}, nil
+}
+
+// scanNumber scans an integer or float literal
+func (l *lexer) scanNumber() tokenKind {
+	if l.peek(0) == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X') {
+		l.advance(2)
+		for isHexDigit(l.peek(0)) {
+			l.advance(1)
+		}
+		return tokenInt
+	}
+
+	kind := tokenInt
+	for isDigit(l.peek(0)) {
+		l.advance(1)
+	}
+	if l.peek(0) == '.' {
+		kind = tokenFloat
+		l.advance(1)
+		for isDigit(l.peek(0)) {
+			l.advance(1)
+		}
+	}
+	if c := l.peek(0); c == 'e' || c == 'E' {
+		kind = tokenFloat
+		l.advance(1)
+		if c := l.peek(0); c == '+' || c == '-' {
+			l.advance(1)
+		}
+		for isDigit(l.peek(0)) {
+			l.advance(1)
+		}
+	}
+	return kind
+}
+
+// scanString scans a quoted string literal and returns the decoded value
+func (l *lexer) scanString() (string, error) {
+	pos := l.position()
+	quote := l.peek(0)
+	l.advance(1)
+
+	var b strings.Builder
+	for {
+		c := l.peek(0)
+		switch {
+		case l.off >= len(l.src) || c == '\n':
+			return "", &Error{Pos: pos, Message: "unterminated string literal"}
+		case c == quote:
+			l.advance(1)
+			return b.String(), nil
+		case c != '\\':
+			b.WriteByte(c)
+			l.advance(1)
+			continue
+		}
+
+		escape := l.peek(1)
+		switch escape {
+		case 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\', '\'', '"', '?':
+			b.WriteByte(map[byte]byte{
+				'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+				'\\': '\\', '\'': '\'', '"': '"', '?': '?',
+			}[escape])
+			l.advance(2)
+		case 'x', 'X':
+			n := 0
+			for n < 2 && isHexDigit(l.peek(2+n)) {
+				n++
+			}
+			if n == 0 {
+				return "", &Error{Pos: l.position(), Message: "invalid hex escape"}
+			}
+			v, _ := strconv.ParseUint(l.src[l.off+2:l.off+2+n], 16, 8)
+			b.WriteByte(byte(v))
+			l.advance(2 + n)
+		case 'u', 'U':
+			n := 4
+			if escape == 'U' {
+				n = 8
+			}
+			if l.off+2+n > len(l.src) {
+				return "", &Error{Pos: l.position(), Message: "invalid unicode escape"}
+			}
+			v, err := strconv.ParseUint(l.src[l.off+2:l.off+2+n], 16, 32)
+			if err != nil {
+				return "", &Error{Pos: l.position(), Message: "invalid unicode escape"}
+			}
+			b.WriteRune(rune(v))
+			l.advance(2 + n)
+		default:
+			if escape < '0' || escape > '7' {
+				return "", &Error{Pos: l.position(), Message: fmt.Sprintf("invalid escape '\\%c'", escape)}
+			}
+			n := 0
+			for n < 3 && l.peek(1+n) >= '0' && l.peek(1+n) <= '7' {
+				n++
+			}
+			v, _ := strconv.ParseUint(l.src[l.off+1:l.off+1+n], 8, 16)
+			b.WriteByte(byte(v))
+			l.advance(1 + n)
+		}
+	}
+}
+
+// isLetter reports whether c is a letter or underscore
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isDigit reports whether c is a decimal digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isHexDigit reports whether c is a hexadecimal digit
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
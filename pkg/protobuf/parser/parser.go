@@ -0,0 +1,713 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Error represents a syntax error in the protobuf source
+type Error struct {
+	// name of the file
+	File string
+
+	// position of the error
+	Pos Position
+
+	Message string
+}
+
+// Error returns the error in form of file:line:column: message
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Pos.Line, e.Pos.Column, e.Message)
+}
+
+// ParseFile parses the protobuf file into syntax tree
+func ParseFile(path string) (*File, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, content)
+}
+
+// Parse parses the protobuf source into syntax tree, the name is used
+// for error messages only
+func Parse(name string, content []byte) (file *File, err error) {
+	tokens, err := lex(string(content))
+	if err != nil {
+		err.(*Error).File = name
+		return nil, err
+	}
+
+	p := &parser{src: string(content), tokens: tokens}
+	defer func() {
+		if r := recover(); r != nil {
+			parseErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			parseErr.File = name
+			file, err = nil, parseErr
+		}
+	}()
+
+	return p.parseFile(name), nil
+}
+
+// parser parses tokens into syntax tree, errors are raised by panic and
+// recovered by Parse
+type parser struct {
+	src    string
+	tokens []*token
+
+	// index of the current token
+	index int
+
+	// the last consumed token
+	last *token
+
+	// comments not attached to any declaration
+	comments []*Comment
+}
+
+// cur returns the current token, the comments before it are collected
+func (p *parser) cur() *token {
+	for p.tokens[p.index].kind == tokenComment {
+		tok := p.tokens[p.index]
+		p.comments = append(p.comments, &Comment{Text: tok.text, Pos: tok.pos, End: tok.end})
+		p.index++
+	}
+	return p.tokens[p.index]
+}
+
+// peek returns the nth token after the current one, comments are skipped
+func (p *parser) peek(n int) *token {
+	for i := p.index; i < len(p.tokens); i++ {
+		if p.tokens[i].kind == tokenComment {
+			continue
+		}
+		if n == 0 {
+			return p.tokens[i]
+		}
+		n--
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+// next consumes and returns the current token
+func (p *parser) next() *token {
+	tok := p.cur()
+	if tok.kind != tokenEOF {
+		p.index++
+	}
+	p.last = tok
+	return tok
+}
+
+// is reports whether the current token is the symbol or keyword
+func (p *parser) is(text string) bool {
+	tok := p.cur()
+	return (tok.kind == tokenSymbol || tok.kind == tokenIdent) && tok.text == text
+}
+
+// accept consumes the current token if it is the symbol or keyword
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect consumes the current token which must be the symbol or keyword
+func (p *parser) expect(text string) *token {
+	if !p.is(text) {
+		p.fail("expected %q", text)
+	}
+	return p.next()
+}
+
+// fail raises an error at the current token
+func (p *parser) fail(format string, args ...interface{}) {
+	tok := p.cur()
+	found := strconv.Quote(tok.text)
+	if tok.kind == tokenEOF {
+		found = "end of file"
+	}
+	panic(&Error{Pos: tok.pos, Message: fmt.Sprintf(format, args...) + ", found " + found})
+}
+
+// takeComments returns the collected comments and resets them
+func (p *parser) takeComments() []*Comment {
+	comments := p.comments
+	p.comments = nil
+	return comments
+}
+
+// trailing consumes the comment follows the last token on the same line,
+// only if no other token follows the comment on its last line
+func (p *parser) trailing() *Comment {
+	tok := p.tokens[p.index]
+	if tok.kind != tokenComment || p.last == nil || tok.pos.Line != p.last.end.Line {
+		return nil
+	}
+	if next := p.tokens[p.index+1]; next.kind != tokenEOF && next.pos.Line == tok.end.Line {
+		return nil
+	}
+
+	p.index++
+	return &Comment{Text: tok.text, Pos: tok.pos, End: tok.end}
+}
+
+// begin starts a declaration at the current token with leading comments
+func (p *parser) begin(element *Element) {
+	p.cur()
+	element.Comments.Leading = p.takeComments()
+	element.Pos = p.cur().pos
+}
+
+// finish ends a declaration at the last token and takes the trailing comment
+func (p *parser) finish(element *Element) {
+	element.End = p.last.end
+	if element.Comments.Trailing == nil {
+		element.Comments.Trailing = p.trailing()
+	}
+}
+
+// ident consumes an identifier
+func (p *parser) ident() string {
+	if p.cur().kind != tokenIdent {
+		p.fail("expected identifier")
+	}
+	return p.next().text
+}
+
+// fullIdent consumes dot separated identifiers
+func (p *parser) fullIdent() string {
+	name := p.ident()
+	for p.is(".") {
+		p.next()
+		name += "." + p.ident()
+	}
+	return name
+}
+
+// typeName consumes a type name, which may be fully qualified by a leading dot
+func (p *parser) typeName() string {
+	if p.accept(".") {
+		return "." + p.fullIdent()
+	}
+	return p.fullIdent()
+}
+
+// stringLit consumes adjacent string literals and returns the joined value
+func (p *parser) stringLit() (string, string) {
+	if p.cur().kind != tokenString {
+		p.fail("expected string")
+	}
+
+	first := p.cur()
+	value := ""
+	for p.cur().kind == tokenString {
+		value += p.next().value
+	}
+	return value, p.src[first.start:p.last.stop]
+}
+
+// intLit consumes an integer with optional minus sign
+func (p *parser) intLit() int {
+	negative := p.accept("-")
+	if p.cur().kind != tokenInt {
+		p.fail("expected integer")
+	}
+
+	tok := p.next()
+	value, err := strconv.ParseInt(tok.text, 0, 64)
+	if err != nil {
+		panic(&Error{Pos: tok.pos, Message: "integer out of range: " + tok.text})
+	}
+	if negative {
+		value = -value
+	}
+	return int(value)
+}
+
+// parseFile parses declarations until the end of file
+func (p *parser) parseFile(name string) *File {
+	file := &File{Name: name, Syntax: "proto2"}
+	file.Pos = Position{Line: 1, Column: 1}
+
+	for p.cur().kind != tokenEOF {
+		var decl Decl
+		switch {
+		case p.is("syntax"):
+			syntax := p.parseSyntax()
+			file.Syntax = syntax.Value
+			decl = syntax
+		case p.is("package"):
+			decl = p.parsePackage()
+		case p.is("import"):
+			decl = p.parseImport()
+		case p.is("option"):
+			decl = p.parseOption()
+		case p.is("message"):
+			decl = p.parseMessage()
+		case p.is("enum"):
+			decl = p.parseEnum()
+		case p.is("service"):
+			decl = p.parseService()
+		case p.is("extend"):
+			decl = p.parseExtend()
+		case p.is(";"):
+			decl = p.parseEmpty()
+		default:
+			p.fail("expected top-level statement")
+		}
+		file.Decls = append(file.Decls, decl)
+	}
+
+	file.EndComments = p.takeComments()
+	file.End = p.cur().pos
+	return file
+}
+
+// parseSyntax parses: syntax = "proto3";
+func (p *parser) parseSyntax() *Syntax {
+	syntax := &Syntax{}
+	p.begin(&syntax.Element)
+	p.expect("syntax")
+	p.expect("=")
+	syntax.Value, _ = p.stringLit()
+	if syntax.Value != "proto2" && syntax.Value != "proto3" {
+		panic(&Error{Pos: p.last.pos, Message: "unrecognized syntax " + strconv.Quote(syntax.Value)})
+	}
+	p.expect(";")
+	p.finish(&syntax.Element)
+	return syntax
+}
+
+// parsePackage parses: package foo.bar;
+func (p *parser) parsePackage() *Package {
+	pkg := &Package{}
+	p.begin(&pkg.Element)
+	p.expect("package")
+	pkg.Name = p.fullIdent()
+	p.expect(";")
+	p.finish(&pkg.Element)
+	return pkg
+}
+
+// parseImport parses: import [public|weak] "path";
+func (p *parser) parseImport() *Import {
+	imp := &Import{}
+	p.begin(&imp.Element)
+	p.expect("import")
+	if p.is("public") || p.is("weak") {
+		imp.Modifier = p.next().text
+	}
+	imp.Path, _ = p.stringLit()
+	p.expect(";")
+	p.finish(&imp.Element)
+	return imp
+}
+
+// parseEmpty parses the empty statement
+func (p *parser) parseEmpty() *Empty {
+	empty := &Empty{}
+	p.begin(&empty.Element)
+	p.expect(";")
+	p.finish(&empty.Element)
+	return empty
+}
+
+// parseOption parses: option name = constant;
+func (p *parser) parseOption() *Option {
+	option := &Option{}
+	p.begin(&option.Element)
+	p.expect("option")
+	p.parseOptionBody(option)
+	p.expect(";")
+	p.finish(&option.Element)
+	return option
+}
+
+// parseCompactOptions parses: [name = constant, ...]
+func (p *parser) parseCompactOptions() []*Option {
+	if !p.accept("[") {
+		return nil
+	}
+
+	var options []*Option
+	for {
+		option := &Option{}
+		option.Pos = p.cur().pos
+		p.parseOptionBody(option)
+		option.End = p.last.end
+		options = append(options, option)
+
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect("]")
+	return options
+}
+
+// parseOptionBody parses name and value of option
+func (p *parser) parseOptionBody(option *Option) {
+	var names []string
+	for {
+		var part OptionNamePart
+		if p.accept("(") {
+			part = OptionNamePart{Name: p.typeName(), Extension: true}
+			p.expect(")")
+			names = append(names, "("+part.Name+")")
+		} else {
+			part = OptionNamePart{Name: p.ident()}
+			names = append(names, part.Name)
+		}
+		option.Parts = append(option.Parts, part)
+
+		if !p.accept(".") {
+			break
+		}
+	}
+	option.Name = strings.Join(names, ".")
+
+	p.expect("=")
+	option.Value = p.parseConstant()
+}
+
+// parseConstant parses identifier, number, string or aggregate value
+func (p *parser) parseConstant() *Constant {
+	first := p.cur()
+	constant := &Constant{}
+	switch {
+	case first.kind == tokenString:
+		constant.Kind = ConstantString
+		constant.String, constant.Raw = p.stringLit()
+		return constant
+	case p.is("{"):
+		constant.Kind = ConstantAggregate
+		p.next()
+		for depth := 1; depth != 0; {
+			switch tok := p.next(); {
+			case tok.kind == tokenEOF:
+				p.fail("expected \"}\"")
+			case tok.text == "{" || tok.text == "<":
+				depth++
+			case tok.text == "}" || tok.text == ">":
+				depth--
+			}
+		}
+		// comments inside the aggregate are kept in the raw text
+		p.comments = nil
+	case p.is("-") || p.is("+"):
+		p.next()
+		switch tok := p.next(); {
+		case tok.kind == tokenInt:
+			constant.Kind = ConstantInt
+		case tok.kind == tokenFloat || (tok.kind == tokenIdent && (tok.text == "inf" || tok.text == "nan")):
+			constant.Kind = ConstantFloat
+		default:
+			panic(&Error{Pos: tok.pos, Message: "expected number, found " + strconv.Quote(tok.text)})
+		}
+	case first.kind == tokenInt:
+		constant.Kind = ConstantInt
+		p.next()
+	case first.kind == tokenFloat:
+		constant.Kind = ConstantFloat
+		p.next()
+	case first.kind == tokenIdent:
+		constant.Kind = ConstantIdent
+		p.fullIdent()
+	default:
+		p.fail("expected constant")
+	}
+
+	constant.Raw = p.src[first.start:p.last.stop]
+	return constant
+}
+
+// parseBody parses declarations in braces by parseDecl, and returns the
+// comments before the closing brace
+func (p *parser) parseBody(element *Element, parseDecl func() Decl) ([]Decl, []*Comment) {
+	p.expect("{")
+	element.Comments.Trailing = p.trailing()
+
+	var decls []Decl
+	for !p.is("}") {
+		if p.cur().kind == tokenEOF {
+			p.fail("expected \"}\"")
+		}
+		decls = append(decls, parseDecl())
+	}
+
+	comments := p.takeComments()
+	p.expect("}")
+	return decls, comments
+}
+
+// parseMessage parses: message Name { ... }
+func (p *parser) parseMessage() *Message {
+	message := &Message{}
+	p.begin(&message.Element)
+	p.expect("message")
+	message.Name = p.ident()
+	message.Decls, message.EndComments = p.parseBody(&message.Element, p.parseMessageDecl)
+	p.finish(&message.Element)
+	return message
+}
+
+// parseMessageDecl parses a declaration in message body
+func (p *parser) parseMessageDecl() Decl {
+	switch {
+	case p.is("message"):
+		return p.parseMessage()
+	case p.is("enum"):
+		return p.parseEnum()
+	case p.is("extend"):
+		return p.parseExtend()
+	case p.is("option"):
+		return p.parseOption()
+	case p.is("oneof"):
+		return p.parseOneof()
+	case p.is("reserved"):
+		return p.parseReserved()
+	case p.is("extensions"):
+		return p.parseExtensions()
+	case p.is(";"):
+		return p.parseEmpty()
+	}
+	return p.parseField(true)
+}
+
+// parseField parses normal field, map field or group
+func (p *parser) parseField(labeled bool) *Field {
+	field := &Field{}
+	p.begin(&field.Element)
+
+	if labeled && (p.is("optional") || p.is("required") || p.is("repeated")) && p.peek(1).text != "=" {
+		field.Label = p.next().text
+	}
+
+	switch {
+	case p.is("map") && p.peek(1).text == "<":
+		p.next()
+		p.expect("<")
+		field.KeyType = p.typeName()
+		p.expect(",")
+		field.ValueType = p.typeName()
+		p.expect(">")
+		field.Type = "map"
+	case p.is("group") && p.peek(1).kind == tokenIdent && p.peek(2).text == "=":
+		p.next()
+		field.Type = "group"
+		field.Group = &Message{}
+	default:
+		field.Type = p.typeName()
+	}
+
+	field.Name = p.ident()
+	p.expect("=")
+	field.Number = p.intLit()
+	field.Options = p.parseCompactOptions()
+
+	if field.Group != nil {
+		field.Group.Name = field.Name
+		field.Group.Pos = field.Pos
+		field.Group.Decls, field.Group.EndComments = p.parseBody(&field.Element, p.parseMessageDecl)
+		field.Group.End = p.last.end
+	} else {
+		p.expect(";")
+	}
+	p.finish(&field.Element)
+	return field
+}
+
+// parseOneof parses: oneof name { ... }
+func (p *parser) parseOneof() *Oneof {
+	oneof := &Oneof{}
+	p.begin(&oneof.Element)
+	p.expect("oneof")
+	oneof.Name = p.ident()
+	oneof.Decls, oneof.EndComments = p.parseBody(&oneof.Element, func() Decl {
+		switch {
+		case p.is("option"):
+			return p.parseOption()
+		case p.is(";"):
+			return p.parseEmpty()
+		}
+		return p.parseField(false)
+	})
+	p.finish(&oneof.Element)
+	return oneof
+}
+
+// parseRanges parses: 1, 2 to 5, 10 to max
+func (p *parser) parseRanges() []Range {
+	var ranges []Range
+	for {
+		r := Range{Start: p.intLit()}
+		r.End = r.Start
+		if p.accept("to") {
+			if p.accept("max") {
+				r.Max = true
+			} else {
+				r.End = p.intLit()
+			}
+		}
+		ranges = append(ranges, r)
+
+		if !p.accept(",") {
+			return ranges
+		}
+	}
+}
+
+// parseReserved parses: reserved 1, 2 to 5; or reserved "foo", "bar";
+func (p *parser) parseReserved() *Reserved {
+	reserved := &Reserved{}
+	p.begin(&reserved.Element)
+	p.expect("reserved")
+
+	if tok := p.cur(); tok.kind == tokenString || tok.kind == tokenIdent {
+		for {
+			if p.cur().kind == tokenIdent {
+				reserved.Names = append(reserved.Names, p.ident())
+			} else {
+				name, _ := p.stringLit()
+				reserved.Names = append(reserved.Names, name)
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	} else {
+		reserved.Ranges = p.parseRanges()
+	}
+
+	p.expect(";")
+	p.finish(&reserved.Element)
+	return reserved
+}
+
+// parseExtensions parses: extensions 100 to max [options];
+func (p *parser) parseExtensions() *Extensions {
+	extensions := &Extensions{}
+	p.begin(&extensions.Element)
+	p.expect("extensions")
+	extensions.Ranges = p.parseRanges()
+	extensions.Options = p.parseCompactOptions()
+	p.expect(";")
+	p.finish(&extensions.Element)
+	return extensions
+}
+
+// parseExtend parses: extend Type { fields }
+func (p *parser) parseExtend() *Extend {
+	extend := &Extend{}
+	p.begin(&extend.Element)
+	p.expect("extend")
+	extend.Type = p.typeName()
+	extend.Decls, extend.EndComments = p.parseBody(&extend.Element, func() Decl {
+		if p.is(";") {
+			return p.parseEmpty()
+		}
+		return p.parseField(true)
+	})
+	p.finish(&extend.Element)
+	return extend
+}
+
+// parseEnum parses: enum Name { ... }
+func (p *parser) parseEnum() *Enum {
+	enum := &Enum{}
+	p.begin(&enum.Element)
+	p.expect("enum")
+	enum.Name = p.ident()
+	enum.Decls, enum.EndComments = p.parseBody(&enum.Element, func() Decl {
+		switch {
+		case p.is("option"):
+			return p.parseOption()
+		case p.is("reserved"):
+			return p.parseReserved()
+		case p.is(";"):
+			return p.parseEmpty()
+		}
+
+		value := &EnumValue{}
+		p.begin(&value.Element)
+		value.Name = p.ident()
+		p.expect("=")
+		value.Number = p.intLit()
+		value.Options = p.parseCompactOptions()
+		p.expect(";")
+		p.finish(&value.Element)
+		return value
+	})
+	p.finish(&enum.Element)
+	return enum
+}
+
+// parseService parses: service Name { ... }
+func (p *parser) parseService() *Service {
+	service := &Service{}
+	p.begin(&service.Element)
+	p.expect("service")
+	service.Name = p.ident()
+	service.Decls, service.EndComments = p.parseBody(&service.Element, func() Decl {
+		switch {
+		case p.is("option"):
+			return p.parseOption()
+		case p.is(";"):
+			return p.parseEmpty()
+		}
+		return p.parseRPC()
+	})
+	p.finish(&service.Element)
+	return service
+}
+
+// parseRPC parses: rpc Name (stream Input) returns (stream Output) { ... }
+func (p *parser) parseRPC() *RPC {
+	rpc := &RPC{}
+	p.begin(&rpc.Element)
+	p.expect("rpc")
+	rpc.Name = p.ident()
+
+	p.expect("(")
+	if p.is("stream") && p.peek(1).text != ")" {
+		p.next()
+		rpc.InputStream = true
+	}
+	rpc.InputType = p.typeName()
+	p.expect(")")
+
+	p.expect("returns")
+	p.expect("(")
+	if p.is("stream") && p.peek(1).text != ")" {
+		p.next()
+		rpc.OutputStream = true
+	}
+	rpc.OutputType = p.typeName()
+	p.expect(")")
+
+	if p.is("{") {
+		rpc.Decls, rpc.EndComments = p.parseBody(&rpc.Element, func() Decl {
+			if p.is(";") {
+				return p.parseEmpty()
+			}
+			return p.parseOption()
+		})
+		if rpc.Decls == nil {
+			rpc.Decls = make([]Decl, 0)
+		}
+	} else {
+		p.expect(";")
+	}
+	p.finish(&rpc.Element)
+	return rpc
+}
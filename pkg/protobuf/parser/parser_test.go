@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSource = `// Copyright header
+
+// Package comment
+syntax = "proto3";
+
+package foo.bar; // trailing package
+
+import "google/protobuf/empty.proto";
+import public "foo/common.proto";
+
+option go_package = "example.com/foo/bar;bar";
+option (gogoproto.goproto_getters_all) = false;
+
+// User represents a user
+message User { // open brace
+  // id of the user
+  int64 id = 1 [(gogoproto.moretags) = "db:\"id\"", deprecated = true];
+  repeated string tags = 2;
+  map<string, Role> roles = 3;
+
+  oneof contact {
+    string email = 4;
+    string phone = 5;
+  }
+
+  enum Role {
+    ROLE_UNSPECIFIED = 0;
+    ROLE_ADMIN = 1 [(custom) = { name: "admin" level: 1 }];
+  }
+
+  reserved 6, 8 to 10, 100 to max;
+  reserved "old", "older";
+
+  // end of user
+}
+
+service UserService {
+  rpc GetUser(GetUserRequest) returns (User);
+  rpc Watch(stream .foo.bar.User) returns (stream User) {
+    option deprecated = true;
+  }
+}
+`
+
+func TestParse(t *testing.T) {
+	file, err := Parse("user.proto", []byte(testSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Syntax != "proto3" || file.Package().Name != "foo.bar" {
+		t.Errorf("syntax = %q, package = %q", file.Syntax, file.Package().Name)
+	}
+	if trailing := file.Package().Comments.Trailing; trailing == nil || trailing.Text != "// trailing package" {
+		t.Errorf("package trailing comment = %+v", trailing)
+	}
+
+	imports := file.Imports()
+	if len(imports) != 2 || imports[1].Path != "foo/common.proto" || imports[1].Modifier != "public" {
+		t.Errorf("imports = %+v", imports)
+	}
+
+	if option := file.Option("go_package"); option == nil || option.Value.String != "example.com/foo/bar;bar" {
+		t.Errorf("go_package = %+v", option)
+	}
+	if option := file.Option("(gogoproto.goproto_getters_all)"); option == nil || option.Value.Kind != ConstantIdent || option.Value.Raw != "false" {
+		t.Errorf("goproto_getters_all = %+v", option)
+	}
+
+	user := file.Messages()[0]
+	if user.Name != "User" || user.Pos.Line != 15 {
+		t.Errorf("message = %q at %+v", user.Name, user.Pos)
+	}
+	if attached := user.Comments.Attached(user.Pos.Line); len(attached) != 1 || attached[0].Text != "// User represents a user" {
+		t.Errorf("attached comments = %+v", attached)
+	}
+	if user.Comments.Trailing == nil || user.Comments.Trailing.Text != "// open brace" {
+		t.Errorf("message trailing comment = %+v", user.Comments.Trailing)
+	}
+	if len(user.EndComments) != 1 || user.EndComments[0].Text != "// end of user" {
+		t.Errorf("message end comments = %+v", user.EndComments)
+	}
+
+	var fields []string
+	for _, field := range user.Fields() {
+		fields = append(fields, field.Label+" "+field.Type+" "+field.Name)
+	}
+	expected := []string{" int64 id", "repeated string tags", " map roles", " string email", " string phone"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("fields = %q", fields)
+	}
+
+	id := user.Fields()[0]
+	if option := id.Option("(gogoproto.moretags)"); option == nil || option.Value.String != `db:"id"` {
+		t.Errorf("moretags = %+v", option)
+	}
+	if roles := user.Fields()[2]; roles.KeyType != "string" || roles.ValueType != "Role" || !roles.IsMap() {
+		t.Errorf("map field = %+v", roles)
+	}
+
+	role := user.Enums()[0]
+	if values := role.Values(); len(values) != 2 || values[1].Options[0].Value.Raw != `{ name: "admin" level: 1 }` {
+		t.Errorf("enum values = %+v", values)
+	}
+
+	var reserved []*Reserved
+	for _, decl := range user.Decls {
+		if r, ok := decl.(*Reserved); ok {
+			reserved = append(reserved, r)
+		}
+	}
+	if len(reserved) != 2 ||
+		!reflect.DeepEqual(reserved[0].Ranges, []Range{{6, 6, false}, {8, 10, false}, {100, 100, true}}) ||
+		!reflect.DeepEqual(reserved[1].Names, []string{"old", "older"}) {
+		t.Errorf("reserved = %+v, %+v", reserved[0], reserved[1])
+	}
+
+	rpcs := file.Services()[0].RPCs()
+	if len(rpcs) != 2 || rpcs[0].Decls != nil {
+		t.Fatalf("rpcs = %+v", rpcs)
+	}
+	if watch := rpcs[1]; !watch.InputStream || !watch.OutputStream || watch.InputType != ".foo.bar.User" || len(watch.Options()) != 1 {
+		t.Errorf("rpc = %+v", watch)
+	}
+}
+
+func TestParseProto2(t *testing.T) {
+	file, err := Parse("legacy.proto", []byte(`
+message Legacy {
+  required int32 id = 1 [default = -1];
+  optional group Result = 2 {
+    optional string url = 3;
+  }
+  extensions 100 to 199;
+}
+
+extend Legacy {
+  optional string extra = 100;
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Syntax != "proto2" {
+		t.Errorf("syntax = %q", file.Syntax)
+	}
+
+	legacy := file.Messages()[0]
+	fields := legacy.Fields()
+	if fields[0].Label != "required" || fields[0].Option("default").Value.Raw != "-1" {
+		t.Errorf("field = %+v", fields[0])
+	}
+	if group := fields[1]; group.Type != "group" || group.Group == nil || group.Group.Fields()[0].Name != "url" {
+		t.Errorf("group = %+v", group)
+	}
+	if extend := file.Extends()[0]; extend.Type != "Legacy" || extend.Fields()[0].Number != 100 {
+		t.Errorf("extend = %+v", extend)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"syntax = \"proto3\"\nmessage Foo {}", `bad.proto:2:1: expected ";", found "message"`},
+		{"message Foo {\n  int32 id = ;\n}", `bad.proto:2:14: expected integer, found ";"`},
+		{"message Foo {\n", `bad.proto:2:1: expected "}", found end of file`},
+		{"option foo = \"bar;\n", `bad.proto:1:14: unterminated string literal`},
+	}
+
+	for _, test := range tests {
+		_, err := Parse("bad.proto", []byte(test.source))
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Parse(%q) error = %v, expected %s", test.source, err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package protobuf
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"protob/pkg/os/fs"
+	"strings"
+)
+
+const (
+	// PluginPrefix is the prefix of the plugin executable name
+	PluginPrefix = "protoc-gen-"
+)
+
+// Languages is the names of code generators built in protoc
+var Languages = []string{"cpp", "csharp", "java", "js", "objc", "php", "python", "ruby"}
+
+// Plugin represents a protoc plugin named protoc-gen-<name> and its output
+type Plugin struct {
+	// name of the plugin without prefix
+	Name string
+
+	// parameters passed to the plugin
+	Parameters []string
+
+	// output directory, the output of runtime by default
+	Out string
+
+	// path of the plugin executable, lookup from plugin directories
+	// and system path by default
+	Path string
+
+	// whether the plugin is a code generator built in protoc, which
+	// requires no executable
+	Builtin bool
+}
+
+// Executable returns the executable name of the plugin
+func (p *Plugin) Executable() string {
+	return PluginPrefix + p.Name + ExecutableSuffix
+}
+
+// String returns the spec of the plugin
+func (p *Plugin) String() string {
+	var options []string
+	if p.Out != "" {
+		options = append(options, "out="+p.Out)
+	}
+	if p.Path != "" {
+		options = append(options, "path="+p.Path)
+	}
+	options = append(options, p.Parameters...)
+
+	if len(options) == 0 {
+		return p.Name
+	}
+	return p.Name + ":" + strings.Join(options, ",")
+}
+
+// ParsePlugin parses plugin from spec 'name:out=dir,path=exe,key=value,...',
+// the out and path are plugin options, others are passed to the plugin
+func ParsePlugin(spec string) (*Plugin, error) {
+	name, options := spec, ""
+	if index := strings.Index(spec, ":"); index >= 0 {
+		name, options = spec[:index], spec[index+1:]
+	}
+
+	name = strings.TrimPrefix(strings.TrimSpace(name), PluginPrefix)
+	if name == "" {
+		return nil, errors.New("plugin: empty name in '" + spec + "'")
+	}
+
+	plugin := &Plugin{Name: name}
+	for _, option := range strings.Split(options, ",") {
+		switch option = strings.TrimSpace(option); {
+		case option == "":
+		case strings.HasPrefix(option, "out="):
+			plugin.Out = strings.TrimPrefix(option, "out=")
+		case strings.HasPrefix(option, "path="):
+			plugin.Path = strings.TrimPrefix(option, "path=")
+		default:
+			plugin.Parameters = append(plugin.Parameters, option)
+		}
+	}
+	return plugin, nil
+}
+
+// ParseLanguage parses built-in generator from spec 'name:out=dir,key=value,...'
+// of the languages built in protoc
+func ParseLanguage(spec string) (*Plugin, error) {
+	plugin, err := ParsePlugin(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !isLanguage(plugin.Name):
+		return nil, fmt.Errorf("plugin: unknown language '%s', expected one of %s", plugin.Name, strings.Join(Languages, ", "))
+	case plugin.Path != "":
+		return nil, fmt.Errorf("plugin: language %s is built in protoc, path is not allowed", plugin.Name)
+	}
+
+	plugin.Builtin = true
+	return plugin, nil
+}
+
+// isLanguage reports whether name is a language built in protoc
+func isLanguage(name string) bool {
+	for _, language := range Languages {
+		if language == name {
+			return true
+		}
+	}
+	return false
+}
+
+// presetPlugins returns the preset plugins of the extension
+func presetPlugins(extension uint8, grpc, sourceRelative bool, module string) []*Plugin {
+	var name string
+	switch extension {
+	case extFast:
+		name = "gogofast"
+	case extFaster:
+		name = "gogofaster"
+	case extSlick:
+		name = "gogoslick"
+	case extGolang:
+		return golangPreset(grpc, sourceRelative, module)
+	default:
+		return nil
+	}
+
+	plugin := &Plugin{Name: name}
+	if grpc {
+		plugin.Parameters = append(plugin.Parameters, "plugins=grpc")
+	}
+	if sourceRelative {
+		plugin.Parameters = append(plugin.Parameters, "paths=source_relative")
+	}
+	return []*Plugin{plugin}
+}
+
+// golangPreset returns protoc-gen-go and protoc-gen-go-grpc plugins, the
+// files are placed by go_package with the module prefix removed, or next
+// to the source file when source relative
+func golangPreset(grpc, sourceRelative bool, module string) []*Plugin {
+	var parameters []string
+	if sourceRelative {
+		parameters = append(parameters, "paths=source_relative")
+	} else if module != "" {
+		parameters = append(parameters, "module="+module)
+	}
+
+	plugins := []*Plugin{{Name: "go", Parameters: parameters}}
+	if grpc {
+		plugins = append(plugins, &Plugin{Name: "go-grpc", Parameters: append([]string{}, parameters...)})
+	}
+	return plugins
+}
+
+// localPlugin returns path of the plugin executable specified explicitly
+// or found in dirs, protoc lookup plugins from system path itself
+func localPlugin(plugin *Plugin, dirs []string) (string, bool) {
+	if plugin.Builtin {
+		return "", false
+	} else if plugin.Path != "" {
+		return plugin.Path, true
+	}
+
+	for _, dir := range dirs {
+		path := fs.Join(dir, plugin.Executable())
+		if ok, _ := fs.IsFile(path); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// lookupPlugin returns path of the plugin executable from dirs or system path
+func lookupPlugin(plugin *Plugin, dirs []string) (string, error) {
+	if plugin.Builtin {
+		return "", fmt.Errorf("plugin: %s is built in protoc", plugin.Name)
+	} else if path, ok := localPlugin(plugin, dirs); ok {
+		return path, nil
+	}
+
+	if path, err := exec.LookPath(plugin.Executable()); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("plugin: %s not found", plugin.Executable())
+}
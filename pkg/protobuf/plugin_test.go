@@ -0,0 +1,110 @@
+package protobuf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlugin(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected *Plugin
+	}{
+		{"go", &Plugin{Name: "go"}},
+		{"protoc-gen-go:out=gen", &Plugin{Name: "go", Out: "gen"}},
+		{"go:out=gen,paths=source_relative", &Plugin{Name: "go", Out: "gen", Parameters: []string{"paths=source_relative"}}},
+		{"go-grpc:path=bin/protoc-gen-go-grpc,require_unimplemented_servers=false", &Plugin{
+			Name:       "go-grpc",
+			Path:       "bin/protoc-gen-go-grpc",
+			Parameters: []string{"require_unimplemented_servers=false"},
+		}},
+	}
+
+	for _, test := range tests {
+		plugin, err := ParsePlugin(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(plugin, test.expected) {
+			t.Errorf("ParsePlugin(%q) = %+v, expected %+v", test.spec, plugin, test.expected)
+		}
+	}
+
+	if _, err := ParsePlugin(":out=gen"); err == nil {
+		t.Error("expected error for plugin without name")
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	language, err := ParseLanguage("python:out=gen/py,pyi_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Plugin{Name: "python", Out: "gen/py", Parameters: []string{"pyi_out"}, Builtin: true}
+	if !reflect.DeepEqual(language, expected) {
+		t.Errorf("ParseLanguage() = %+v, expected %+v", language, expected)
+	}
+
+	for _, spec := range []string{"go:out=gen", "java:path=bin/protoc-gen-java"} {
+		if _, err := ParseLanguage(spec); err == nil {
+			t.Errorf("ParseLanguage(%q) expected error", spec)
+		}
+	}
+
+	if _, err := lookupPlugin(language, nil); err == nil {
+		t.Errorf("lookupPlugin() of built-in language expected error")
+	}
+}
+
+func TestCompilerRuntimePlugins(t *testing.T) {
+	tests := []struct {
+		options  []CompileOption
+		expected []string
+	}{
+		{nil, []string{"--gogoslick_out=api"}},
+		{[]CompileOption{WithExtFast(true), WithGrpc(true)}, []string{"--gogofast_out=plugins=grpc:api"}},
+		{
+			[]CompileOption{WithPlugins(&Plugin{Name: "go", Out: "gen", Parameters: []string{"paths=source_relative"}}, &Plugin{Name: "go-grpc"})},
+			[]string{"--go_out=paths=source_relative:gen", "--go-grpc_out=api"},
+		},
+		{
+			[]CompileOption{WithGolang(true), WithGrpc(true), WithGoModule("example.com/svc"), WithOutput("gen")},
+			[]string{"--go_out=module=example.com/svc:gen", "--go-grpc_out=module=example.com/svc:gen"},
+		},
+		{
+			[]CompileOption{WithGolang(true), WithSourceRelative(true), WithGoModule("example.com/svc")},
+			[]string{"--go_out=paths=source_relative:api"},
+		},
+		{
+			[]CompileOption{WithPlugins(&Plugin{Name: "go"}), WithExtSlick(true), WithOutput("out")},
+			[]string{"--gogoslick_out=out", "--go_out=out"},
+		},
+		{
+			[]CompileOption{WithLanguages(&Plugin{Name: "python", Out: "gen/py"}, &Plugin{Name: "java", Parameters: []string{"lite"}})},
+			[]string{"--gogoslick_out=api", "--python_out=gen/py", "--java_out=lite:api"},
+		},
+		{
+			[]CompileOption{WithoutExtSlick(), WithLanguages(&Plugin{Name: "python"})},
+			[]string{"--python_out=api"},
+		},
+		{
+			[]CompileOption{WithExtSlick(true), WithLanguages(&Plugin{Name: "python"})},
+			[]string{"--gogoslick_out=api", "--python_out=api"},
+		},
+		{[]CompileOption{WithoutExtSlick()}, []string{}},
+		{[]CompileOption{WithExtSlick(true), WithoutExtSlick()}, []string{}},
+		{[]CompileOption{WithoutExtSlick(), WithExtFast(true)}, []string{"--gogofast_out=api"}},
+	}
+
+	for _, test := range tests {
+		runtime := &CompilerRuntime{}
+		for _, option := range test.options {
+			option(runtime)
+		}
+
+		args := runtime.Build([]string{"api/echo.proto"})
+		if got := args[2 : len(args)-1]; !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Build() = %v, expected %v", got, test.expected)
+		}
+	}
+}
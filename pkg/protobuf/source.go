@@ -0,0 +1,125 @@
+package protobuf
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"protob/pkg/protobuf/parser"
+	"sort"
+)
+
+// Source represents the header declarations of a protobuf file
+type Source struct {
+	// package name declared by package statement
+	Package string
+
+	// import paths declared by import statements
+	Imports []string
+}
+
+// ScanFile scans package and imports declared in the protobuf file
+func ScanFile(path string) (*Source, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ScanSource(content), nil
+}
+
+// ScanSource scans package and imports declared in the protobuf content,
+// the content not parsed has no declarations, leaving the syntax errors
+// to be reported by the compiler
+func ScanSource(content []byte) *Source {
+	source := &Source{}
+	file, err := parser.Parse("", content)
+	if err != nil {
+		return source
+	}
+
+	if pkg := file.Package(); pkg != nil {
+		source.Package = pkg.Name
+	}
+	for _, imp := range file.Imports() {
+		source.Imports = append(source.Imports, imp.Path)
+	}
+	return source
+}
+
+// GroupTargets groups targets by the directory and the declared package,
+// files in the same group should be compiled in one invocation
+func GroupTargets(targets []string) ([][]string, error) {
+	var keys []string
+	groups := make(map[string][]string)
+	for _, target := range targets {
+		source, err := ScanFile(target)
+		if err != nil {
+			return nil, err
+		}
+
+		key := fs.NormalizePath(filepath.Dir(target)) + "\x00" + source.Package
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], target)
+	}
+
+	sort.Strings(keys)
+	var result [][]string
+	for _, key := range keys {
+		sort.Strings(groups[key])
+		result = append(result, groups[key])
+	}
+	return result, nil
+}
+
+// ResolveImport lookup the imported file from include paths in order
+func ResolveImport(includes []string, name string) (string, bool) {
+	for _, include := range includes {
+		path := fs.Join(include, name)
+		if ok, _ := fs.IsFile(path); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// Imports returns files imported by targets transitively which resolved
+// from include paths, and the import paths unable to resolve
+func Imports(includes []string, targets []string) ([]string, []string, error) {
+	var resolved, missing []string
+	visited, unresolved := make(map[string]bool), make(map[string]bool)
+
+	queue := append([]string{}, targets...)
+	for _, target := range targets {
+		visited[fs.NormalizePath(target)] = true
+	}
+
+	for len(queue) != 0 {
+		source, err := ScanFile(queue[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		queue = queue[1:]
+
+		for _, name := range source.Imports {
+			path, ok := ResolveImport(includes, name)
+			if !ok {
+				if !unresolved[name] {
+					unresolved[name] = true
+					missing = append(missing, name)
+				}
+				continue
+			}
+
+			if !visited[path] {
+				visited[path] = true
+				resolved = append(resolved, path)
+				queue = append(queue, path)
+			}
+		}
+	}
+
+	sort.Strings(resolved)
+	sort.Strings(missing)
+	return resolved, missing, nil
+}
@@ -0,0 +1,54 @@
+package protobuf
+
+import (
+	"protob/pkg/os/fs"
+	"protob/pkg/os/fs/fstest"
+	"reflect"
+	"testing"
+)
+
+func TestScanSource(t *testing.T) {
+	source := ScanSource([]byte(`// package commented;
+syntax = "proto3";
+/* import "commented.proto"; */
+package hello.world;
+
+import "google/protobuf/empty.proto";
+import public 'common/types.proto';
+
+message Content {
+    string value = 1; // import "inline.proto";
+    string package = 2;
+    string import = 3;
+}
+`))
+
+	if source.Package != "hello.world" {
+		t.Errorf("package = %q, expected %q", source.Package, "hello.world")
+	}
+	if expected := []string{"google/protobuf/empty.proto", "common/types.proto"}; !reflect.DeepEqual(source.Imports, expected) {
+		t.Errorf("imports = %v, expected %v", source.Imports, expected)
+	}
+}
+
+func TestScanSourceInvalid(t *testing.T) {
+	source := ScanSource([]byte("syntax = \"proto3\";\npackage broken;\nimport \"a.proto\";\nmessage {\n"))
+	if source.Package != "" || len(source.Imports) != 0 {
+		t.Errorf("ScanSource() = %+v, expected no declarations of invalid source", source)
+	}
+}
+
+func TestGroupTargetsWithPackageField(t *testing.T) {
+	root := fstest.TempDir(t, map[string]string{
+		"api/a.proto": "syntax = \"proto3\";\npackage api;\nmessage A { string package = 1; }\n",
+		"api/b.proto": "syntax = \"proto3\";\npackage api;\nmessage B { string name = 1; }\n",
+	})
+
+	groups, err := GroupTargets([]string{fs.Join(root, "api/a.proto"), fs.Join(root, "api/b.proto")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Errorf("GroupTargets() = %v, expected files of the same package in one group", groups)
+	}
+}
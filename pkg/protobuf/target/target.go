@@ -0,0 +1,226 @@
+package target
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"sort"
+	"strings"
+)
+
+const (
+	// IgnoreFile is the name of file which lists patterns to exclude
+	IgnoreFile = ".protobignore"
+
+	// Extension of the protobuf source file
+	Extension = ".proto"
+
+	// recursiveSuffix represents all protobuf files under the directory recursively
+	recursiveSuffix = "/..."
+)
+
+// Exclusion represents a set of patterns to exclude targets
+type Exclusion struct {
+	// absolute base directory of the patterns
+	base string
+
+	// patterns to exclude
+	patterns []string
+}
+
+// Add add patterns into exclusion, the pattern without slash matches the
+// file or directory name at any depth, otherwise relative to the base
+func (e *Exclusion) Add(patterns ...string) {
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(fs.NormalizePath(pattern)); pattern != "" {
+			e.patterns = append(e.patterns, strings.TrimPrefix(pattern, "./"))
+		}
+	}
+}
+
+// Match reports whether the path should be excluded
+func (e *Exclusion) Match(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(e.base, abs)
+	if err != nil {
+		return false
+	}
+	rel = fs.NormalizePath(rel)
+
+	for _, pattern := range e.patterns {
+		dirOnly := strings.HasSuffix(pattern, "/")
+		if pattern = strings.TrimSuffix(pattern, "/"); dirOnly && !isDir {
+			continue
+		}
+
+		if strings.Contains(pattern, "/") {
+			if fs.Match(strings.TrimPrefix(pattern, "/"), rel) {
+				return true
+			}
+		} else if fs.Match(pattern, fs.NormalizePath(filepath.Base(rel))) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewExclusion create an exclusion which patterns relative to base
+func NewExclusion(base string, patterns ...string) (*Exclusion, error) {
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusion := &Exclusion{base: abs}
+	exclusion.Add(patterns...)
+	return exclusion, nil
+}
+
+// LoadExclusion create an exclusion with patterns from the ignore file in base
+func LoadExclusion(base string, patterns ...string) (*Exclusion, error) {
+	exclusion, err := NewExclusion(base, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(base, IgnoreFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return exclusion, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && line[0] != '#' {
+			exclusion.Add(line)
+		}
+	}
+	return exclusion, scanner.Err()
+}
+
+// Expand expands patterns into protobuf files, a pattern can be
+//   - a protobuf file: api/echo.proto
+//   - a directory, all protobuf files in it: api
+//   - a directory with '/...', all protobuf files under it recursively: api/...
+//   - a glob pattern, '**' matches any directories: api/**/*.proto
+func Expand(patterns []string, exclusion *Exclusion) ([]string, error) {
+	seen := make(map[string]bool)
+	var targets []string
+
+	for _, pattern := range patterns {
+		matches, err := expand(fs.NormalizePath(pattern), exclusion)
+		if err != nil {
+			return nil, err
+		} else if len(matches) == 0 {
+			return nil, fmt.Errorf("target: '%s' matched no protobuf files", pattern)
+		}
+
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				targets = append(targets, match)
+			}
+		}
+	}
+
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// Root returns the directory holding every file the pattern may match, e.g.
+// api for api/..., api/**/*.proto and api/user.proto
+func Root(pattern string) string {
+	pattern = fs.NormalizePath(pattern)
+	switch {
+	case strings.HasSuffix(pattern, recursiveSuffix) || pattern == "...":
+		if root := strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/"); root != "" {
+			return root
+		}
+		return "."
+	case fs.HasMeta(pattern):
+		root, _ := fs.SplitPattern(pattern)
+		return root
+	case strings.HasSuffix(pattern, Extension):
+		return fs.NormalizePath(filepath.Dir(pattern))
+	}
+	return pattern
+}
+
+// expand expands a pattern into protobuf files
+func expand(pattern string, exclusion *Exclusion) ([]string, error) {
+	if strings.HasSuffix(pattern, recursiveSuffix) || pattern == "..." {
+		root := strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+		if root == "" {
+			root = "."
+		}
+		return walk(root, true, exclusion, func(string) bool { return true })
+	}
+
+	if fs.HasMeta(pattern) {
+		root, glob := fs.SplitPattern(pattern)
+		return walk(root, true, exclusion, func(rel string) bool {
+			return fs.Match(glob, rel)
+		})
+	}
+
+	isDir, err := fs.IsDir(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir {
+		if exclusion != nil && exclusion.Match(pattern, false) {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	return walk(pattern, false, exclusion, func(string) bool { return true })
+}
+
+// walk walks the file tree rooted at root, collect protobuf files which
+// path relative to root is accepted by the filter
+func walk(root string, recursive bool, exclusion *Exclusion, filter func(rel string) bool) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path == root {
+				return nil
+			}
+			if !recursive || strings.HasPrefix(info.Name(), ".") || (exclusion != nil && exclusion.Match(path, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) != Extension || (exclusion != nil && exclusion.Match(path, false)) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if filter(fs.NormalizePath(rel)) {
+			files = append(files, fs.NormalizePath(path))
+		}
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return files, err
+}
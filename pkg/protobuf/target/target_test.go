@@ -0,0 +1,92 @@
+package target
+
+import (
+	"path/filepath"
+	"protob/pkg/os/fs"
+	"protob/pkg/os/fs/fstest"
+	"reflect"
+	"testing"
+)
+
+func expandIn(t *testing.T, root string, exclusion *Exclusion, patterns ...string) []string {
+	var joined []string
+	for _, pattern := range patterns {
+		joined = append(joined, fs.Join(root, pattern))
+	}
+
+	targets, err := Expand(joined, exclusion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result []string
+	for _, target := range targets {
+		rel, _ := filepath.Rel(root, target)
+		result = append(result, fs.NormalizePath(rel))
+	}
+	return result
+}
+
+func TestExpand(t *testing.T) {
+	root := fstest.TempDir(t, fstest.Files(
+		"api/a.proto", "api/b.proto", "api/readme.md",
+		"api/v1/c.proto", "api/v1/inner/d.proto",
+		"api/.hidden/e.proto", "third_party/f.proto",
+	))
+
+	tests := []struct {
+		patterns []string
+		expected []string
+	}{
+		{[]string{"api/a.proto"}, []string{"api/a.proto"}},
+		{[]string{"api"}, []string{"api/a.proto", "api/b.proto"}},
+		{[]string{"api/..."}, []string{"api/a.proto", "api/b.proto", "api/v1/c.proto", "api/v1/inner/d.proto"}},
+		{[]string{"api/*/*.proto"}, []string{"api/v1/c.proto"}},
+		{[]string{"api/**/*.proto"}, []string{"api/a.proto", "api/b.proto", "api/v1/c.proto", "api/v1/inner/d.proto"}},
+		{[]string{"api/a.proto", "api"}, []string{"api/a.proto", "api/b.proto"}},
+	}
+
+	for _, test := range tests {
+		if got := expandIn(t, root, nil, test.patterns...); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expand(%v) = %v, expected %v", test.patterns, got, test.expected)
+		}
+	}
+}
+
+func TestExpandNoMatches(t *testing.T) {
+	root := fstest.TempDir(t, fstest.Files("api/readme.md"))
+	if _, err := Expand([]string{fs.Join(root, "api/...")}, nil); err == nil {
+		t.Error("expected error for pattern matched nothing")
+	}
+}
+
+func TestExclusion(t *testing.T) {
+	root := fstest.TempDir(t, fstest.Files("api/a.proto", "api/v1/b.proto", "api/internal/c.proto", "vendor/d.proto"))
+	fstest.WriteFiles(t, root, map[string]string{IgnoreFile: "# comment\ninternal/\napi/v1/*.proto\n"})
+
+	exclusion, err := LoadExclusion(root, "vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := expandIn(t, root, exclusion, "...")
+	if expected := []string{"api/a.proto"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expand with exclusion = %v, expected %v", got, expected)
+	}
+}
+
+func TestRoot(t *testing.T) {
+	tests := map[string]string{
+		"api/...":        "api",
+		"...":            ".",
+		"api/**/*.proto": "api",
+		"*.proto":        ".",
+		"api/v1":         "api/v1",
+		"api/user.proto": "api",
+	}
+	for pattern, expected := range tests {
+		if root := Root(pattern); root != expected {
+			t.Errorf("Root(%q) = %s, expected %s", pattern, root, expected)
+		}
+	}
+}